@@ -5,6 +5,7 @@ go 1.22.2
 require (
 	github.com/go-chi/chi/v5 v5.2.1
 	github.com/gomarkdown/markdown v0.0.0-20250311123330-531bef5e742b
+	github.com/mattiaswal/go-libyang v0.0.0-20250423141307-1a382f7d923c
 	github.com/mattiaswal/go-sysrepo v0.0.0-20250424172848-73168dd016cf
 	github.com/msteinert/pam v1.2.0
 	github.com/shirou/gopsutil/v3 v3.24.5
@@ -14,7 +15,6 @@ require (
 require (
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/lufia/plan9stats v0.0.0-20211012122336-39d0f177ccd0 // indirect
-	github.com/mattiaswal/go-libyang v0.0.0-20250423141307-1a382f7d923c // indirect
 	github.com/power-devops/perfstat v0.0.0-20210106213030-5aafc221ea8c // indirect
 	github.com/shoenig/go-m1cpu v0.1.6 // indirect
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
@@ -22,3 +22,6 @@ require (
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 )
+
+// Sysrepo errors with their messages and paths, see third_party/go-sysrepo
+replace github.com/mattiaswal/go-sysrepo => ./third_party/go-sysrepo
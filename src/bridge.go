@@ -0,0 +1,598 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/go-chi/chi/v5"
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// Bridge attributes, from linux/if_link.h and linux/if_bridge.h
+const (
+	iflaAfSpec         = 26
+	iflaExtMask        = 29
+	iflaBrportState    = 1
+	bridgeVlanInfo     = 2
+	bridgeVlanPVID     = 0x2
+	bridgeVlanUntagged = 0x4
+	rtextFilterBrvlan  = 0x2
+)
+
+// Neighbour attributes, and states and flags, from linux/neighbour.h
+const (
+	sizeofNdMsg   = 12
+	ndaLladdr     = 2
+	ndaVlan       = 5
+	ndaMaster     = 9
+	nudNoarp      = 0x40
+	nudPermanent  = 0x80
+	ntfExtLearned = 0x10
+	ntfOffloaded  = 0x20
+	ntfSticky     = 0x40
+)
+
+// Names of port STP states, BR_STATE_*
+var stpStates = []string{"disabled", "listening", "learning", "forwarding", "blocking"}
+
+// Membership of a port in a VLAN, as in the bridge model
+const (
+	vlanTagged   = "tagged"
+	vlanUntagged = "untagged"
+)
+
+// PortVLAN is the membership of a port in a VLAN
+type PortVLAN struct {
+	VID      int
+	Untagged bool
+}
+
+// BridgePort is a port of a bridge, as set up in the kernel
+type BridgePort struct {
+	Name     string
+	State    string
+	STPState string
+	PVID     int
+	VLANs    []PortVLAN
+}
+
+// FDBEntry is an entry of the forwarding database of a bridge
+type FDBEntry struct {
+	MAC   string
+	Port  string
+	VLAN  int
+	Flags []string
+}
+
+// BridgeVLAN is the configured membership of the ports in a VLAN, by
+// port name
+type BridgeVLAN struct {
+	VID     int
+	Members map[string]string
+}
+
+// BridgeConfig is the configured VLANs of a bridge, and the PVIDs of
+// its ports.  The bridge itself is the first port.
+type BridgeConfig struct {
+	Ports []string
+	VLANs []BridgeVLAN
+	PVIDs map[string]string
+	New   BridgeVLAN
+}
+
+// Bridge is a bridge, its ports and forwarding database
+type Bridge struct {
+	Name   string
+	HWAddr string
+	State  string
+	Self   BridgePort
+	Ports  []BridgePort
+	FDB    []FDBEntry
+	Config *BridgeConfig
+	Errors map[string]string
+}
+
+// BridgeInfo holds data for the bridge page
+type BridgeInfo struct {
+	Bridges []Bridge
+	Message string
+	Error   string
+}
+
+// bridgeVLANJSON is the VLANs of a bridge, infix-if-bridge
+type bridgeVLANJSON struct {
+	Vlans struct {
+		Vlan []struct {
+			Vid      int      `json:"vid"`
+			Untagged []string `json:"untagged"`
+			Tagged   []string `json:"tagged"`
+		} `json:"vlan"`
+	} `json:"vlans"`
+}
+
+// bridgePortState reads the STP state and VLANs of all bridge ports,
+// and bridges, by index
+func bridgePortState() (map[int]BridgePort, error) {
+	body := make([]byte, sizeofIfInfomsg)
+	body[0] = syscall.AF_BRIDGE
+	body = appendAttr(body, iflaExtMask, binary.NativeEndian.AppendUint32(nil, rtextFilterBrvlan))
+
+	msgs, err := netlinkRequest(syscall.RTM_GETLINK, syscall.NLM_F_DUMP, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make(map[int]BridgePort)
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWLINK || len(m.Data) < sizeofIfInfomsg {
+			continue
+		}
+
+		index := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		port := BridgePort{}
+		for _, attr := range parseAttrs(m.Data[sizeofIfInfomsg:]) {
+			switch attr.Type {
+			case syscall.IFLA_PROTINFO:
+				// Older kernels send the state only, not nested
+				state := -1
+				if len(attr.Value) == 1 {
+					state = int(attr.Value[0])
+				}
+				for _, info := range parseAttrs(attr.Value) {
+					if info.Type == iflaBrportState && len(info.Value) > 0 {
+						state = int(info.Value[0])
+					}
+				}
+				if state >= 0 && state < len(stpStates) {
+					port.STPState = stpStates[state]
+				}
+			case iflaAfSpec:
+				for _, info := range parseAttrs(attr.Value) {
+					if info.Type != bridgeVlanInfo || len(info.Value) < 4 {
+						continue
+					}
+					flags := binary.NativeEndian.Uint16(info.Value[0:2])
+					vid := int(binary.NativeEndian.Uint16(info.Value[2:4]))
+					if flags&bridgeVlanPVID != 0 {
+						port.PVID = vid
+					}
+					port.VLANs = append(port.VLANs, PortVLAN{VID: vid, Untagged: flags&bridgeVlanUntagged != 0})
+				}
+			}
+		}
+
+		ports[index] = port
+	}
+
+	return ports, nil
+}
+
+// bridgeFDB reads the forwarding database of all bridges, by index of
+// the bridge
+func bridgeFDB(names map[int]string) (map[int][]FDBEntry, error) {
+	msgs, err := netlinkDump(syscall.RTM_GETNEIGH, syscall.AF_BRIDGE)
+	if err != nil {
+		return nil, err
+	}
+
+	fdb := make(map[int][]FDBEntry)
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < sizeofNdMsg {
+			continue
+		}
+
+		index := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		flags := m.Data[10]
+
+		entry := FDBEntry{Port: names[index]}
+		master := 0
+		for _, attr := range parseAttrs(m.Data[sizeofNdMsg:]) {
+			switch attr.Type {
+			case ndaLladdr:
+				entry.MAC = net.HardwareAddr(attr.Value).String()
+			case ndaVlan:
+				if len(attr.Value) >= 2 {
+					entry.VLAN = int(binary.NativeEndian.Uint16(attr.Value))
+				}
+			case ndaMaster:
+				master = int(attrUint32(attr.Value))
+			}
+		}
+
+		// Entries of the ports themselves, e.g., multicast
+		// filters, have no master
+		if master == 0 {
+			continue
+		}
+
+		switch {
+		case state&nudPermanent != 0:
+			entry.Flags = append(entry.Flags, "permanent")
+		case state&nudNoarp != 0:
+			entry.Flags = append(entry.Flags, "static")
+		default:
+			entry.Flags = append(entry.Flags, "dynamic")
+		}
+		if flags&ntfExtLearned != 0 {
+			entry.Flags = append(entry.Flags, "extern_learn")
+		}
+		if flags&ntfOffloaded != 0 {
+			entry.Flags = append(entry.Flags, "offload")
+		}
+		if flags&ntfSticky != 0 {
+			entry.Flags = append(entry.Flags, "sticky")
+		}
+
+		fdb[master] = append(fdb[master], entry)
+	}
+
+	for _, entries := range fdb {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].VLAN != entries[j].VLAN {
+				return entries[i].VLAN < entries[j].VLAN
+			}
+			return entries[i].MAC < entries[j].MAC
+		})
+	}
+
+	return fdb, nil
+}
+
+// getBridges returns the bridges of the kernel, their ports and
+// forwarding database, and their configuration if sysrepo is available
+func getBridges() ([]Bridge, error) {
+	links, names, err := getLinks()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := bridgePortState()
+	if err != nil {
+		return nil, err
+	}
+
+	fdb, err := bridgeFDB(names)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without sysrepo the bridges are shown, but cannot be edited
+	running, err := getInterfacesJSON(sr.DSRunning)
+	if err != nil && !errors.Is(err, errNoData) {
+		log.Printf("Bridge configuration unavailable: %v", err)
+	}
+
+	var bridges []Bridge
+	for _, l := range links {
+		if l.Kind != "bridge" {
+			continue
+		}
+
+		bridge := Bridge{
+			Name:   l.Name,
+			HWAddr: l.HWAddr,
+			State:  l.State,
+			Self:   state[l.Index],
+			FDB:    fdb[l.Index],
+		}
+		bridge.Self.Name = l.Name
+
+		for _, p := range links {
+			if p.Master != l.Index {
+				continue
+			}
+			port := state[p.Index]
+			port.Name, port.State = p.Name, p.State
+			bridge.Ports = append(bridge.Ports, port)
+		}
+		sort.Slice(bridge.Ports, func(i, j int) bool {
+			return bridge.Ports[i].Name < bridge.Ports[j].Name
+		})
+
+		if running != nil {
+			bridge.Config = bridgeConfig(running, l.Name)
+		}
+
+		bridges = append(bridges, bridge)
+	}
+
+	return bridges, nil
+}
+
+// bridgeConfig returns the configured VLANs of a bridge, and the PVIDs
+// of its ports
+func bridgeConfig(running *interfacesJSON, name string) *BridgeConfig {
+	cfg := &BridgeConfig{
+		Ports: []string{name},
+		PVIDs: make(map[string]string),
+	}
+
+	var ports []string
+	for _, i := range running.Interfaces.Interface {
+		if i.BridgePort != nil && i.BridgePort.Bridge == name {
+			ports = append(ports, i.Name)
+			cfg.PVIDs[i.Name] = i.BridgePort.PVID.String()
+		}
+	}
+	sort.Strings(ports)
+	cfg.Ports = append(cfg.Ports, ports...)
+
+	for _, i := range running.Interfaces.Interface {
+		if i.Name != name || i.Bridge == nil {
+			continue
+		}
+
+		for _, v := range i.Bridge.Vlans.Vlan {
+			vlan := BridgeVLAN{VID: v.Vid, Members: make(map[string]string)}
+			for _, port := range v.Tagged {
+				vlan.Members[port] = vlanTagged
+			}
+			for _, port := range v.Untagged {
+				vlan.Members[port] = vlanUntagged
+			}
+			cfg.VLANs = append(cfg.VLANs, vlan)
+		}
+	}
+	sort.Slice(cfg.VLANs, func(i, j int) bool {
+		return cfg.VLANs[i].VID < cfg.VLANs[j].VID
+	})
+
+	return cfg
+}
+
+// Member returns the membership of a port in the VLAN, for the forms
+func (v BridgeVLAN) Member(port string) string {
+	return v.Members[port]
+}
+
+// VLANString returns the VLANs of a port, as in bridge vlan show
+func (p BridgePort) VLANString() string {
+	var vlans []string
+	for _, v := range p.VLANs {
+		s := strconv.Itoa(v.VID)
+		if v.Untagged {
+			s += "u"
+		}
+		vlans = append(vlans, s)
+	}
+	return strings.Join(vlans, " ")
+}
+
+// bridgeVLANPath returns the xpath of a VLAN of a bridge
+func bridgeVLANPath(bridge string, vid int) string {
+	return fmt.Sprintf("%s/infix-interfaces:bridge/vlans/vlan[vid='%d']", interfacePath(bridge), vid)
+}
+
+// parseVID parses a VLAN ID
+func parseVID(value string) (int, bool) {
+	vid, err := strconv.Atoi(value)
+	return vid, err == nil && vid >= 1 && vid <= 4094
+}
+
+// parseBridgeForm reads and validates the VLANs of a bridge from a
+// form, using the ports and VLANs currently configured
+func parseBridgeForm(r *http.Request, old *BridgeConfig) (*BridgeConfig, map[string]string) {
+	cfg := &BridgeConfig{
+		Ports: old.Ports,
+		PVIDs: make(map[string]string),
+		New:   BridgeVLAN{Members: make(map[string]string)},
+	}
+	errs := make(map[string]string)
+
+	member := func(field string) string {
+		switch value := r.FormValue(field); value {
+		case vlanTagged, vlanUntagged:
+			return value
+		}
+		return ""
+	}
+
+	for _, v := range old.VLANs {
+		vlan := BridgeVLAN{VID: v.VID, Members: make(map[string]string)}
+		for _, port := range cfg.Ports {
+			if m := member(fmt.Sprintf("vlan-%d-%s", v.VID, port)); m != "" {
+				vlan.Members[port] = m
+			}
+		}
+		cfg.VLANs = append(cfg.VLANs, vlan)
+	}
+
+	if value := strings.TrimSpace(r.FormValue("new-vid")); value != "" {
+		vid, ok := parseVID(value)
+		if !ok {
+			errs["new-vid"] = "Must be a number from 1 to 4094"
+		}
+		for _, v := range old.VLANs {
+			if v.VID == vid {
+				errs["new-vid"] = fmt.Sprintf("VLAN %d already exists", vid)
+			}
+		}
+		cfg.New.VID = vid
+		for _, port := range cfg.Ports {
+			if m := member("vlan-new-" + port); m != "" {
+				cfg.New.Members[port] = m
+			}
+		}
+	}
+
+	for _, port := range cfg.Ports[1:] {
+		pvid := strings.TrimSpace(r.FormValue("pvid-" + port))
+		cfg.PVIDs[port] = pvid
+		if _, ok := parseVID(pvid); pvid != "" && !ok {
+			errs["pvid-"+port] = "Must be a number from 1 to 4094"
+		}
+	}
+
+	return cfg, errs
+}
+
+// setBridgeConfig changes the VLAN membership of the ports of a bridge,
+// and their PVIDs.  Items rejected by sysrepo are reported as problems
+// of their VLAN, or port.
+func setBridgeConfig(sess *sr.Session, bridge string, old, cfg *BridgeConfig, errs map[string]string) error {
+	field := func(name string, err error) {
+		if err != nil && errs[name] == "" {
+			errs[name] = "Rejected: " + err.Error()
+		}
+	}
+
+	members := func(vid int) map[string]string {
+		for _, v := range old.VLANs {
+			if v.VID == vid {
+				return v.Members
+			}
+		}
+		return nil
+	}
+
+	vlans := cfg.VLANs
+	if cfg.New.VID != 0 {
+		vlans = append(vlans, cfg.New)
+		field("new-vid", sess.SetItem(bridgeVLANPath(bridge, cfg.New.VID), nil, sr.EditDefault))
+	}
+
+	for _, v := range vlans {
+		path := bridgeVLANPath(bridge, v.VID)
+		name := fmt.Sprintf("vlan-%d", v.VID)
+		if v.VID == cfg.New.VID {
+			name = "new-vid"
+		}
+
+		was := members(v.VID)
+		for _, port := range cfg.Ports {
+			if was[port] == v.Members[port] {
+				continue
+			}
+			if was[port] != "" {
+				field(name, sess.DeleteItem(fmt.Sprintf("%s/%s[.='%s']", path, was[port], port), sr.EditDefault))
+			}
+			if m := v.Members[port]; m != "" {
+				field(name, sess.SetItem(path+"/"+m, &port, sr.EditDefault))
+			}
+		}
+	}
+
+	for _, port := range cfg.Ports[1:] {
+		if cfg.PVIDs[port] == old.PVIDs[port] {
+			continue
+		}
+		base := interfacePath(port) + "/infix-interfaces:bridge-port"
+		field("pvid-"+port, setItems(sess, base, map[string]string{"pvid": cfg.PVIDs[port]}))
+	}
+
+	if len(errs) > 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+// renderBridges renders the bridge page with the result of a change
+func renderBridges(w http.ResponseWriter, r *http.Request, message, failure string) {
+	info := &BridgeInfo{Message: message, Error: failure}
+
+	bridges, err := getBridges()
+	if err != nil {
+		log.Printf("Error getting bridges: %v", err)
+		http.Error(w, "Failed to get bridges", http.StatusInternalServerError)
+		return
+	}
+	info.Bridges = bridges
+
+	renderPage(w, r, "bridge", info)
+}
+
+// bridgeHandler shows the bridges
+func bridgeHandler(w http.ResponseWriter, r *http.Request) {
+	renderBridges(w, r, "", "")
+}
+
+// findBridge returns a bridge, if it is configured
+func findBridge(bridges []Bridge, name string) *Bridge {
+	for i := range bridges {
+		if bridges[i].Name == name && bridges[i].Config != nil {
+			return &bridges[i]
+		}
+	}
+	return nil
+}
+
+// saveBridgeVLANsHandler changes the VLANs of a bridge
+func saveBridgeVLANsHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	bridges, err := getBridges()
+	if err != nil {
+		log.Printf("Error getting bridges: %v", err)
+		http.Error(w, "Failed to get bridges", http.StatusInternalServerError)
+		return
+	}
+	bridge := findBridge(bridges, name)
+	if bridge == nil {
+		http.Error(w, "No such bridge", http.StatusNotFound)
+		return
+	}
+
+	cfg, errs := parseBridgeForm(r, bridge.Config)
+	if len(errs) == 0 {
+		err = editConfig(func(sess *sr.Session) error {
+			return setBridgeConfig(sess, name, bridge.Config, cfg, errs)
+		})
+	}
+
+	if len(errs) > 0 || err != nil {
+		// Show the form again, as entered, with the problems
+		info := &BridgeInfo{Bridges: bridges}
+		if err != nil && !errors.Is(err, errInvalid) {
+			log.Printf("Error saving VLANs of %s: %v", name, err)
+			info.Error = fmt.Sprintf("Failed to save VLANs of %s: %v", name, err)
+		}
+		bridge.Config, bridge.Errors = cfg, errs
+		renderPage(w, r, "bridge", info)
+		return
+	}
+
+	log.Printf("VLANs of %s applied by %s", name, getUsername(r))
+	renderBridges(w, r, fmt.Sprintf("VLANs of %s applied", name), "")
+}
+
+// deleteBridgeVLANHandler removes a VLAN from a bridge
+func deleteBridgeVLANHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	vid, ok := parseVID(r.FormValue("vid"))
+	if !ok {
+		http.Error(w, "Invalid VLAN", http.StatusBadRequest)
+		return
+	}
+
+	bridges, err := getBridges()
+	if err != nil {
+		log.Printf("Error getting bridges: %v", err)
+		http.Error(w, "Failed to get bridges", http.StatusInternalServerError)
+		return
+	}
+	if findBridge(bridges, name) == nil {
+		http.Error(w, "No such bridge", http.StatusNotFound)
+		return
+	}
+
+	err = editConfig(func(sess *sr.Session) error {
+		return sess.DeleteItem(bridgeVLANPath(name, vid), sr.EditDefault)
+	})
+	if err != nil {
+		log.Printf("Error deleting VLAN %d of %s: %v", vid, name, err)
+		renderBridges(w, r, "", fmt.Sprintf("Failed to delete VLAN %d of %s: %v", vid, name, err))
+		return
+	}
+
+	log.Printf("VLAN %d of %s deleted by %s", vid, name, getUsername(r))
+	renderBridges(w, r, fmt.Sprintf("VLAN %d of %s deleted", vid, name), "")
+}
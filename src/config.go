@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// ConfigStatus tells if there are changes to the running configuration
+// not saved to startup, shown in the banner of every page
+type ConfigStatus struct {
+	Unsaved bool
+	Message string
+	Error   string
+}
+
+// configChanged is a middleware for handlers changing the running
+// configuration, telling the page to update the banner
+func configChanged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("HX-Trigger", "configChanged")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getConfigStatus compares the running and startup configuration
+func getConfigStatus() *ConfigStatus {
+	unsaved, err := unsavedConfig()
+	if err != nil {
+		log.Printf("Error comparing running and startup configuration: %v", err)
+		return &ConfigStatus{}
+	}
+
+	return &ConfigStatus{Unsaved: unsaved}
+}
+
+// configBannerHandler renders the banner for unsaved changes
+func configBannerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	renderFragment(w, "config-banner", getConfigStatus())
+}
+
+// saveConfigHandler saves the running configuration to startup
+func saveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if err := saveConfig(); err != nil {
+		log.Printf("Error saving running configuration to startup: %v", err)
+		status := getConfigStatus()
+		status.Error = "Failed to save: " + errorMessage(err)
+		renderFragment(w, "config-banner", status)
+		return
+	}
+
+	log.Printf("Running configuration saved to startup by %s", getUsername(r))
+
+	status := getConfigStatus()
+	if !status.Unsaved {
+		status.Message = "Running configuration saved to startup"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	renderFragment(w, "config-banner", status)
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// KernelMessage is a record of the kernel ring buffer
+type KernelMessage struct {
+	Seq       int64
+	Level     int
+	Facility  int
+	Uptime    time.Duration
+	Time      time.Time
+	Message   string
+	Subsystem string
+	Device    string
+}
+
+// DmesgInfo is the kernel log page
+type DmesgInfo struct {
+	Messages []KernelMessage
+	Level    string
+	Levels   []string
+	Last     int64
+	Error    string
+}
+
+// Largest record /dev/kmsg returns, one record per read
+const kmsgRecordSize = 8192
+
+// LevelName returns the name of the log level
+func (m *KernelMessage) LevelName() string {
+	return severityNames[m.Level]
+}
+
+// LevelClass returns the Bootstrap class of the level badge
+func (m *KernelMessage) LevelClass() string {
+	return severityClass(m.Level)
+}
+
+// RowClass returns the Bootstrap class of the message in the table
+func (m *KernelMessage) RowClass() string {
+	return severityRowClass(m.Level)
+}
+
+// UptimeString returns the time since boot, like dmesg does
+func (m *KernelMessage) UptimeString() string {
+	return fmt.Sprintf("%12.6f", m.Uptime.Seconds())
+}
+
+// TimeString returns the wall clock time of the message
+func (m *KernelMessage) TimeString() string {
+	return m.Time.Format(logTimestamp)
+}
+
+// bootTime returns when the system was booted, from /proc/uptime.
+// Kernel timestamps do not include time in suspend, so messages from
+// before a suspend get an earlier time than they should.
+func bootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("invalid /proc/uptime")
+	}
+
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid /proc/uptime: %w", err)
+	}
+
+	return time.Now().Add(-time.Duration(uptime * float64(time.Second))), nil
+}
+
+// kmsgReader reads records from /dev/kmsg, without blocking
+type kmsgReader struct {
+	fd   int
+	boot time.Time
+	buf  []byte
+}
+
+// openKmsg opens the kernel ring buffer, reading starts at the oldest
+// record still in the buffer
+func openKmsg() (*kmsgReader, error) {
+	boot, err := bootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/kmsg: %w", err)
+	}
+
+	return &kmsgReader{fd: fd, boot: boot, buf: make([]byte, kmsgRecordSize)}, nil
+}
+
+// Close closes the ring buffer
+func (k *kmsgReader) Close() {
+	syscall.Close(k.fd)
+}
+
+// Read returns the records added since the last read, with a sequence
+// number after seq, the first record has sequence number 0
+func (k *kmsgReader) Read(seq int64) ([]KernelMessage, error) {
+	var messages []KernelMessage
+
+	for {
+		n, err := syscall.Read(k.fd, k.buf)
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err == syscall.EPIPE {
+			// Overwritten before we got to it, continue with the next
+			continue
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return messages, err
+		}
+		if n == 0 {
+			break
+		}
+
+		msg, ok := parseKmsgRecord(k.buf[:n], k.boot)
+		if ok && msg.Seq > seq {
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages, nil
+}
+
+// parseKmsgRecord parses a record from /dev/kmsg:
+//
+//	priority,sequence,microseconds,flags[,...];message
+//	 SUBSYSTEM=net
+//	 DEVICE=+net:eth0
+//
+// Non-printable characters in the message are escaped as \xHH.
+func parseKmsgRecord(record []byte, boot time.Time) (KernelMessage, bool) {
+	var msg KernelMessage
+
+	lines := strings.Split(strings.TrimSuffix(string(record), "\n"), "\n")
+	header, text, ok := strings.Cut(lines[0], ";")
+	if !ok {
+		return msg, false
+	}
+
+	fields := strings.Split(header, ",")
+	if len(fields) < 3 {
+		return msg, false
+	}
+
+	pri, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return msg, false
+	}
+	msg.Level = pri & 7
+	msg.Facility = pri >> 3
+
+	if msg.Seq, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return msg, false
+	}
+
+	usec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return msg, false
+	}
+	msg.Uptime = time.Duration(usec) * time.Microsecond
+	msg.Time = boot.Add(msg.Uptime)
+	msg.Message = unescapeKmsg(text)
+
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, " "), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "SUBSYSTEM":
+			msg.Subsystem = value
+		case "DEVICE":
+			msg.Device = value
+		}
+	}
+
+	return msg, true
+}
+
+// unescapeKmsg replaces \xHH escapes with the character
+func unescapeKmsg(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// filterKernelMessages returns the messages of the level, or worse
+func filterKernelMessages(messages []KernelMessage, level int) []KernelMessage {
+	if level < 0 {
+		return messages
+	}
+
+	var matching []KernelMessage
+	for _, msg := range messages {
+		if msg.Level <= level {
+			matching = append(matching, msg)
+		}
+	}
+
+	return matching
+}
+
+// parseLevel parses the level query parameter, -1 is all levels
+func parseLevel(r *http.Request) (string, int, error) {
+	name := r.URL.Query().Get("level")
+	if name == "" {
+		return "", -1, nil
+	}
+
+	level := parseSeverity(name)
+	if level < 0 {
+		return name, -1, fmt.Errorf("invalid level %q", name)
+	}
+
+	return name, level, nil
+}
+
+// dmesgHandler shows the kernel ring buffer
+func dmesgHandler(w http.ResponseWriter, r *http.Request) {
+	info := &DmesgInfo{Levels: severityNames, Last: -1}
+
+	name, level, err := parseLevel(r)
+	info.Level = name
+	if err != nil {
+		info.Error = err.Error()
+		renderPage(w, r, "dmesg", info)
+		return
+	}
+
+	kmsg, err := openKmsg()
+	if err != nil {
+		log.Printf("Error reading kernel log: %v", err)
+		info.Error = "Failed to read the kernel log"
+		renderPage(w, r, "dmesg", info)
+		return
+	}
+	defer kmsg.Close()
+
+	messages, err := kmsg.Read(-1)
+	if err != nil {
+		log.Printf("Error reading kernel log: %v", err)
+		info.Error = "Failed to read the kernel log"
+	}
+
+	// Follow from the last message, also when it is filtered out
+	if len(messages) > 0 {
+		info.Last = messages[len(messages)-1].Seq
+	}
+	info.Messages = filterKernelMessages(messages, level)
+
+	renderPage(w, r, "dmesg", info)
+}
+
+// kernelEntry is a followed kernel message, for the table
+type kernelEntry struct {
+	Uptime  string `json:"uptime"`
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Badge   string `json:"badge"`
+	Row     string `json:"row,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Message string `json:"message"`
+}
+
+// followDmesgHandler streams new kernel messages, after the sequence
+// number in the "after" parameter, as Server-Sent Events
+func followDmesgHandler(w http.ResponseWriter, r *http.Request) {
+	_, level, err := parseLevel(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A reconnecting EventSource continues where it left off
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("after")
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		seq = -1
+	}
+
+	kmsg, err := openKmsg()
+	if err != nil {
+		log.Printf("Error following kernel log: %v", err)
+		http.Error(w, "Failed to read the kernel log", http.StatusInternalServerError)
+		return
+	}
+	defer kmsg.Close()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	poll := time.NewTicker(followInterval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(followKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+
+		case <-poll.C:
+			messages, err := kmsg.Read(seq)
+			if err != nil {
+				log.Printf("Error following kernel log: %v", err)
+				writeEvent(w, "error", []string{"Failed to read the kernel log"})
+				rc.Flush()
+				return
+			}
+			if len(messages) == 0 {
+				continue
+			}
+			seq = messages[len(messages)-1].Seq
+
+			var lines []string
+			for _, msg := range filterKernelMessages(messages, level) {
+				data, err := json.Marshal(kernelEntry{
+					Uptime:  msg.UptimeString(),
+					Time:    msg.TimeString(),
+					Level:   msg.LevelName(),
+					Badge:   msg.LevelClass(),
+					Row:     msg.RowClass(),
+					Device:  msg.Device,
+					Message: msg.Message,
+				})
+				if err == nil {
+					lines = append(lines, string(data))
+				}
+			}
+			if len(lines) > 0 {
+				writeEvent(w, "", lines)
+			}
+			fmt.Fprintf(w, "id: %d\n\n", seq)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseKmsgRecord(t *testing.T) {
+	boot := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+
+	// Records as read from /dev/kmsg, one per read, but for the one
+	// with a caller id, of kernels with CONFIG_PRINTK_CALLER
+	tests := []struct {
+		name   string
+		record string
+		ok     bool
+		want   KernelMessage
+	}{
+		{
+			name:   "First record",
+			record: "5,0,0,-;Linux version 6.18.44 (builder@sandboxing) (gcc (GCC) 15.3.0, GNU ld (GNU Binutils) 2.46) #1 SMP PREEMPT_DYNAMIC @0\n",
+			ok:     true,
+			want: KernelMessage{
+				Level:   5,
+				Time:    boot,
+				Message: "Linux version 6.18.44 (builder@sandboxing) (gcc (GCC) 15.3.0, GNU ld (GNU Binutils) 2.46) #1 SMP PREEMPT_DYNAMIC @0",
+			},
+		},
+		{
+			name:   "Debug",
+			record: "7,18,180,-;e820: update [mem 0x00000000-0x00000fff] usable ==> reserved\n",
+			ok:     true,
+			want: KernelMessage{
+				Seq:     18,
+				Level:   7,
+				Uptime:  180 * time.Microsecond,
+				Time:    boot.Add(180 * time.Microsecond),
+				Message: "e820: update [mem 0x00000000-0x00000fff] usable ==> reserved",
+			},
+		},
+		{
+			name:   "Device",
+			record: "6,187,155576,-;acpi PNP0A08:00: _OSC: OS supports [ExtendedConfig ASPM ClockPM Segments MSI HPX-Type3]\n SUBSYSTEM=acpi\n DEVICE=+acpi:PNP0A08:00\n",
+			ok:     true,
+			want: KernelMessage{
+				Seq:       187,
+				Level:     6,
+				Uptime:    155576 * time.Microsecond,
+				Time:      boot.Add(155576 * time.Microsecond),
+				Message:   "acpi PNP0A08:00: _OSC: OS supports [ExtendedConfig ASPM ClockPM Segments MSI HPX-Type3]",
+				Subsystem: "acpi",
+				Device:    "+acpi:PNP0A08:00",
+			},
+		},
+		{
+			name:   "Tab escaped",
+			record: `6,87,97567,-;rcu: \x09RCU restricting CPUs from NR_CPUS=256 to nr_cpu_ids=1.` + "\n",
+			ok:     true,
+			want: KernelMessage{
+				Seq:     87,
+				Level:   6,
+				Uptime:  97567 * time.Microsecond,
+				Time:    boot.Add(97567 * time.Microsecond),
+				Message: "rcu: \tRCU restricting CPUs from NR_CPUS=256 to nr_cpu_ids=1.",
+			},
+		},
+		{
+			name:   "From user space",
+			record: `14,354,7852839125,-;rauc: installing "C:\x5c\x5cbundles\x5c\x5cinfix.pkg"\x09\x1b[1mdone\x1b[0m` + "\n",
+			ok:     true,
+			want: KernelMessage{
+				Seq:      354,
+				Level:    6,
+				Facility: 1,
+				Uptime:   7852839125 * time.Microsecond,
+				Time:     boot.Add(7852839125 * time.Microsecond),
+				Message:  "rauc: installing \"C:\\\\bundles\\\\infix.pkg\"\t\x1b[1mdone\x1b[0m",
+			},
+		},
+		{
+			name:   "Continued, with caller",
+			record: "4,1024,5000000,c,caller=T1;net eth0: link up\n",
+			ok:     true,
+			want: KernelMessage{
+				Seq:     1024,
+				Level:   4,
+				Uptime:  5 * time.Second,
+				Time:    boot.Add(5 * time.Second),
+				Message: "net eth0: link up",
+			},
+		},
+		{
+			name:   "No message",
+			record: "6,1,0,-\n",
+		},
+		{
+			name:   "Short header",
+			record: "6,1;Command line: console=ttyS0\n",
+		},
+		{
+			name:   "Bad priority",
+			record: "x,1,0,-;Command line: console=ttyS0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseKmsgRecord([]byte(tt.record), boot)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("%s: parseKmsgRecord() =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnescapeKmsg(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{`\x09indented`, "\tindented"},
+		{`ends with \x0a`, "ends with \n"},
+		{`back\x5cslash`, `back\slash`},
+		{`\x5cx41 is not unescaped twice`, `\x41 is not unescaped twice`},
+		{`short \x4`, `short \x4`},
+		{`not hex \xzz`, `not hex \xzz`},
+		{`\x`, `\x`},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeKmsg(tt.in); got != tt.want {
+			t.Errorf("unescapeKmsg(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ethtool ioctl, and its commands, from linux/ethtool.h
+const (
+	siocEthtool            = 0x8946
+	ethtoolGLinkSettings   = 0x4c
+	ethtoolGModuleInfo     = 0x42
+	ethtoolGModuleEEPROM   = 0x43
+	ethModuleSFF8079       = 0x1
+	ethModuleSFF8472       = 0x2
+	ethModuleSFF8636       = 0x3
+	ethModuleSFF8436       = 0x4
+	ethModuleSFF8636MaxLen = 640
+
+	// Most 32-bit words of each link mode mask, link_mode_masks_nwords
+	// is an __s8
+	ethtoolLinkModeMaskMaxWords = 127
+)
+
+// Link mode bits, of the link mode masks of struct
+// ethtool_link_settings, as named by ethtool.  Port, FEC and
+// autonegotiation bits are left out.
+var linkModes = []string{
+	0:  "10baseT/Half",
+	1:  "10baseT/Full",
+	2:  "100baseT/Half",
+	3:  "100baseT/Full",
+	4:  "1000baseT/Half",
+	5:  "1000baseT/Full",
+	12: "10000baseT/Full",
+	13: "Pause",
+	14: "Asym_Pause",
+	15: "2500baseX/Full",
+	17: "1000baseKX/Full",
+	18: "10000baseKX4/Full",
+	19: "10000baseKR/Full",
+	21: "20000baseMLD2/Full",
+	22: "20000baseKR2/Full",
+	23: "40000baseKR4/Full",
+	24: "40000baseCR4/Full",
+	25: "40000baseSR4/Full",
+	26: "40000baseLR4/Full",
+	27: "56000baseKR4/Full",
+	28: "56000baseCR4/Full",
+	29: "56000baseSR4/Full",
+	30: "56000baseLR4/Full",
+	31: "25000baseCR/Full",
+	32: "25000baseKR/Full",
+	33: "25000baseSR/Full",
+	34: "50000baseCR2/Full",
+	35: "50000baseKR2/Full",
+	36: "100000baseKR4/Full",
+	37: "100000baseSR4/Full",
+	38: "100000baseCR4/Full",
+	39: "100000baseLR4_ER4/Full",
+	40: "50000baseSR2/Full",
+	41: "1000baseX/Full",
+	42: "10000baseCR/Full",
+	43: "10000baseSR/Full",
+	44: "10000baseLR/Full",
+	45: "10000baseLRM/Full",
+	46: "10000baseER/Full",
+	47: "2500baseT/Full",
+	48: "5000baseT/Full",
+	52: "50000baseKR/Full",
+	53: "50000baseSR/Full",
+	54: "50000baseCR/Full",
+	55: "50000baseLR_ER_FR/Full",
+	56: "50000baseDR/Full",
+	57: "100000baseKR2/Full",
+	58: "100000baseSR2/Full",
+	59: "100000baseCR2/Full",
+	60: "100000baseLR2_ER2_FR2/Full",
+	61: "100000baseDR2/Full",
+	62: "200000baseKR4/Full",
+	63: "200000baseSR4/Full",
+	64: "200000baseLR4_ER4_FR4/Full",
+	65: "200000baseDR4/Full",
+	66: "200000baseCR4/Full",
+	67: "100baseT1/Full",
+	68: "1000baseT1/Full",
+	69: "400000baseKR8/Full",
+	70: "400000baseSR8/Full",
+	71: "400000baseLR8_ER8_FR8/Full",
+	72: "400000baseDR8/Full",
+	73: "400000baseCR8/Full",
+	75: "100000baseKR/Full",
+	76: "100000baseSR/Full",
+	77: "100000baseLR_ER_FR/Full",
+	78: "100000baseCR/Full",
+	79: "100000baseDR/Full",
+	80: "200000baseKR2/Full",
+	81: "200000baseSR2/Full",
+	82: "200000baseLR2_ER2_FR2/Full",
+	83: "200000baseDR2/Full",
+	84: "200000baseCR2/Full",
+	85: "400000baseKR4/Full",
+	86: "400000baseSR4/Full",
+	87: "400000baseLR4_ER4_FR4/Full",
+	88: "400000baseDR4/Full",
+	89: "400000baseCR4/Full",
+	90: "100baseFX/Half",
+	91: "100baseFX/Full",
+	92: "10baseT1L/Full",
+}
+
+// Names of connector ports
+var ethPorts = map[uint8]string{
+	0x00: "Twisted Pair",
+	0x01: "AUI",
+	0x02: "BNC",
+	0x03: "MII",
+	0x04: "FIBRE",
+	0x05: "Direct Attach Copper",
+	0xef: "None",
+	0xff: "Other",
+}
+
+// ethtoolLinkSettings is struct ethtool_link_settings, followed by
+// room for the supported, advertised and link partner mode masks
+type ethtoolLinkSettings struct {
+	Cmd                 uint32
+	Speed               uint32
+	Duplex              uint8
+	Port                uint8
+	PhyAddress          uint8
+	Autoneg             uint8
+	MdioSupport         uint8
+	EthTpMdix           uint8
+	EthTpMdixCtrl       uint8
+	LinkModeMasksNwords int8
+	Transceiver         uint8
+	MasterSlaveCfg      uint8
+	MasterSlaveState    uint8
+	RateMatching        uint8
+	Reserved            [7]uint32
+	LinkModeMasks       [3 * ethtoolLinkModeMaskMaxWords]uint32
+}
+
+// ethtoolModinfo is struct ethtool_modinfo
+type ethtoolModinfo struct {
+	Cmd       uint32
+	Type      uint32
+	EepromLen uint32
+	Reserved  [8]uint32
+}
+
+// ethtoolEeprom is struct ethtool_eeprom, followed by its data
+type ethtoolEeprom struct {
+	Cmd    uint32
+	Magic  uint32
+	Offset uint32
+	Len    uint32
+	Data   [ethModuleSFF8636MaxLen]byte
+}
+
+// ifreq is struct ifreq, with a pointer to the ethtool command
+type ifreq struct {
+	Name [syscall.IFNAMSIZ]byte
+	Data unsafe.Pointer
+	_    [16]byte
+}
+
+// EthernetInfo is the link settings of an Ethernet port, from sysrepo
+// or, if not available, from the kernel with ethtool
+type EthernetInfo struct {
+	Source     string
+	Speed      string
+	Duplex     string
+	Autoneg    bool
+	Port       string
+	Supported  []string
+	Advertised []string
+	Partner    []string
+	Module     *ModuleInfo
+}
+
+// ModuleInfo is the identity, and diagnostics, of a pluggable
+// transceiver, e.g., an SFP
+type ModuleInfo struct {
+	Type        string
+	Vendor      string
+	PartNumber  string
+	Revision    string
+	Serial      string
+	HasDOM      bool
+	Temperature float64
+	Voltage     float64
+	TxBias      float64
+	TxPower     float64
+	RxPower     float64
+}
+
+// ethtool runs an ethtool command on an interface
+func ethtool(ifname string, cmd unsafe.Pointer) error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var req ifreq
+	copy(req.Name[:syscall.IFNAMSIZ-1], ifname)
+	req.Data = cmd
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// modeNames returns the names of the bits set in a link mode mask
+func modeNames(mask []uint32) []string {
+	var names []string
+	for bit, name := range linkModes {
+		if name != "" && bit/32 < len(mask) && mask[bit/32]&(1<<(bit%32)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// getLinkSettings returns the link settings of an Ethernet port, with
+// ETHTOOL_GLINKSETTINGS.  The first request, without room for the mode
+// masks, returns their size, negated, for the second.
+func getLinkSettings(ifname string) (*ethtoolLinkSettings, error) {
+	settings := &ethtoolLinkSettings{Cmd: ethtoolGLinkSettings}
+	if err := ethtool(ifname, unsafe.Pointer(settings)); err != nil {
+		return nil, err
+	}
+	if settings.LinkModeMasksNwords >= 0 {
+		return nil, fmt.Errorf("no link mode mask size")
+	}
+
+	nwords := -settings.LinkModeMasksNwords
+	*settings = ethtoolLinkSettings{Cmd: ethtoolGLinkSettings, LinkModeMasksNwords: nwords}
+	if err := ethtool(ifname, unsafe.Pointer(settings)); err != nil {
+		return nil, err
+	}
+	if settings.LinkModeMasksNwords != nwords {
+		return nil, fmt.Errorf("link mode mask size changed")
+	}
+
+	return settings, nil
+}
+
+// getEthernetInfo returns the link settings, and any module, of an
+// Ethernet port.  Ports without link settings, e.g., bridges, return
+// an error.
+func getEthernetInfo(ifname string) (*EthernetInfo, error) {
+	info, err := getLinkInfo(ifname)
+	if err != nil {
+		return nil, err
+	}
+
+	// Most ports have no module, only report real errors
+	info.Module, _ = getModuleInfo(ifname)
+
+	return info, nil
+}
+
+// getLinkInfo returns the link settings of an Ethernet port, without
+// reading the EEPROM of any module, which is slow over I2C.  Speed,
+// duplex and auto-negotiation are the operational data of sysrepo, the
+// port and link modes, not in its model, are read with ethtool, as is
+// everything if sysrepo is not available.
+func getLinkInfo(ifname string) (*EthernetInfo, error) {
+	settings, serr := getLinkSettings(ifname)
+
+	info, err := getOperEthernet(ifname)
+	if err != nil {
+		if serr != nil {
+			return nil, fmt.Errorf("no link settings: %w", serr)
+		}
+		return linkSettingsInfo(settings), nil
+	}
+
+	if serr == nil {
+		n := int(settings.LinkModeMasksNwords)
+		info.Port = ethPorts[settings.Port]
+		info.Supported = modeNames(settings.LinkModeMasks[:n])
+		info.Advertised = modeNames(settings.LinkModeMasks[n : 2*n])
+		info.Partner = modeNames(settings.LinkModeMasks[2*n : 3*n])
+	}
+
+	return info, nil
+}
+
+// linkSettingsInfo returns the link settings read with ethtool
+func linkSettingsInfo(settings *ethtoolLinkSettings) *EthernetInfo {
+	n := int(settings.LinkModeMasksNwords)
+	info := &EthernetInfo{
+		Source:     "ethtool",
+		Speed:      "Unknown",
+		Duplex:     "Unknown",
+		Autoneg:    settings.Autoneg != 0,
+		Port:       ethPorts[settings.Port],
+		Supported:  modeNames(settings.LinkModeMasks[:n]),
+		Advertised: modeNames(settings.LinkModeMasks[n : 2*n]),
+		Partner:    modeNames(settings.LinkModeMasks[2*n : 3*n]),
+	}
+
+	if speed := settings.Speed; speed != 0 && speed != 0xffffffff {
+		info.Speed = speedString(int64(speed))
+	}
+	switch settings.Duplex {
+	case 0x00:
+		info.Duplex = "Half"
+	case 0x01:
+		info.Duplex = "Full"
+	}
+
+	return info
+}
+
+// speedString formats a link speed in Mb/s
+func speedString(mbps int64) string {
+	if mbps >= 1000 && mbps%1000 == 0 {
+		return fmt.Sprintf("%d Gb/s", mbps/1000)
+	}
+	return fmt.Sprintf("%d Mb/s", mbps)
+}
+
+// getModuleInfo reads the EEPROM of a pluggable module, with ethtool
+// since modules are not in the operational data of sysrepo
+func getModuleInfo(ifname string) (*ModuleInfo, error) {
+	modinfo := ethtoolModinfo{Cmd: ethtoolGModuleInfo}
+	if err := ethtool(ifname, unsafe.Pointer(&modinfo)); err != nil {
+		return nil, err
+	}
+
+	eeprom := ethtoolEeprom{Cmd: ethtoolGModuleEEPROM}
+	eeprom.Len = min(modinfo.EepromLen, ethModuleSFF8636MaxLen)
+	if err := ethtool(ifname, unsafe.Pointer(&eeprom)); err != nil {
+		return nil, err
+	}
+	data := eeprom.Data[:eeprom.Len]
+
+	switch modinfo.Type {
+	case ethModuleSFF8079, ethModuleSFF8472:
+		return parseSFF8472(data), nil
+	case ethModuleSFF8636, ethModuleSFF8436:
+		return parseSFF8636(data), nil
+	}
+
+	return nil, fmt.Errorf("unknown module type %d", modinfo.Type)
+}
+
+// eepromString returns a space padded ASCII field of an EEPROM
+func eepromString(data []byte, start, end int) string {
+	if len(data) < end {
+		return ""
+	}
+	return strings.TrimSpace(string(data[start:end]))
+}
+
+// eepromUint16 returns a big endian value of an EEPROM
+func eepromUint16(data []byte, offset int) uint16 {
+	return binary.BigEndian.Uint16(data[offset:])
+}
+
+// parseSFF8472 parses the EEPROM of an SFP, the diagnostics (DOM), if
+// any, are in the second page at 256.  Power is in mW.
+func parseSFF8472(data []byte) *ModuleInfo {
+	module := &ModuleInfo{
+		Type:       "SFP",
+		Vendor:     eepromString(data, 20, 36),
+		PartNumber: eepromString(data, 40, 56),
+		Revision:   eepromString(data, 56, 60),
+		Serial:     eepromString(data, 68, 84),
+	}
+
+	// Diagnostic monitoring type, bit 6 is set when implemented
+	const dom = 256
+	if len(data) < dom+106 || data[92]&0x40 == 0 {
+		return module
+	}
+
+	module.HasDOM = true
+	module.Temperature = float64(int16(eepromUint16(data, dom+96))) / 256
+	module.Voltage = float64(eepromUint16(data, dom+98)) / 10000
+	module.TxBias = float64(eepromUint16(data, dom+100)) * 0.002
+	module.TxPower = float64(eepromUint16(data, dom+102)) / 10000
+	module.RxPower = float64(eepromUint16(data, dom+104)) / 10000
+
+	return module
+}
+
+// parseSFF8636 parses the EEPROM of a QSFP, power of the first lane
+func parseSFF8636(data []byte) *ModuleInfo {
+	module := &ModuleInfo{
+		Type:       "QSFP",
+		Vendor:     eepromString(data, 148, 164),
+		PartNumber: eepromString(data, 168, 184),
+		Revision:   eepromString(data, 184, 186),
+		Serial:     eepromString(data, 196, 212),
+	}
+
+	if len(data) < 52 {
+		return module
+	}
+
+	module.HasDOM = true
+	module.Temperature = float64(int16(eepromUint16(data, 22))) / 256
+	module.Voltage = float64(eepromUint16(data, 26)) / 10000
+	module.RxPower = float64(eepromUint16(data, 34)) / 10000
+	module.TxBias = float64(eepromUint16(data, 42)) * 0.002
+	module.TxPower = float64(eepromUint16(data, 50)) / 10000
+
+	return module
+}
+
+// powerString formats optical power, in mW and dBm
+func powerString(mw float64) string {
+	if mw <= 0 {
+		return "0.0000 mW (-inf dBm)"
+	}
+	return fmt.Sprintf("%.4f mW (%.2f dBm)", mw, 10*math.Log10(mw))
+}
+
+// TxPowerString returns the transmit power for display
+func (m *ModuleInfo) TxPowerString() string {
+	return powerString(m.TxPower)
+}
+
+// RxPowerString returns the receive power for display
+func (m *ModuleInfo) RxPowerString() string {
+	return powerString(m.RxPower)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModeNames(t *testing.T) {
+	tests := []struct {
+		name string
+		mask []uint32
+		want []string
+	}{
+		{
+			name: "1G copper",
+			mask: []uint32{1<<1 | 1<<3 | 1<<5 | 1<<6 | 1<<7 | 1<<13},
+			want: []string{"10baseT/Full", "100baseT/Full", "1000baseT/Full", "Pause"},
+		},
+		{
+			name: "25G SFP28",
+			mask: []uint32{1<<19 | 1<<31, 1<<(33-32) | 1<<(43-32) | 1<<(50-32), 0},
+			want: []string{"10000baseKR/Full", "25000baseCR/Full", "25000baseSR/Full", "10000baseSR/Full"},
+		},
+		{
+			name: "100G QSFP28",
+			mask: []uint32{0, 1<<(36-32) | 1<<(37-32) | 1<<(38-32) | 1<<(39-32), 1 << (91 - 64)},
+			want: []string{"100000baseKR4/Full", "100000baseSR4/Full", "100000baseCR4/Full", "100000baseLR4_ER4/Full", "100baseFX/Full"},
+		},
+		{
+			name: "Unknown modes",
+			mask: []uint32{0, 0, 0, 0xffffffff},
+		},
+		{
+			name: "Short mask",
+			mask: []uint32{1 << 5},
+			want: []string{"1000baseT/Full"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := modeNames(tt.mask); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: modeNames(%#x) = %q, want %q", tt.name, tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestParseOperEthernet(t *testing.T) {
+	data := []byte(`{
+  "ietf-interfaces:interfaces": {
+    "interface": [
+      {
+        "name": "e1",
+        "ieee802-ethernet-interface:ethernet": {
+          "auto-negotiation": {"enable": true},
+          "speed": "1.0",
+          "duplex": "full"
+        }
+      },
+      {
+        "name": "e2",
+        "ieee802-ethernet-interface:ethernet": {
+          "auto-negotiation": {"enable": false},
+          "speed": "0.1",
+          "duplex": "half"
+        }
+      },
+      {
+        "name": "e3",
+        "ieee802-ethernet-interface:ethernet": {
+          "auto-negotiation": {"enable": true},
+          "speed": "2.5",
+          "duplex": "unknown"
+        }
+      },
+      {
+        "name": "e4",
+        "ieee802-ethernet-interface:ethernet": {
+          "auto-negotiation": {"enable": true},
+          "speed": "0.0"
+        }
+      },
+      {"name": "br0"}
+    ]
+  }
+}`)
+
+	tests := []struct {
+		ifname string
+		want   *EthernetInfo
+	}{
+		{"e1", &EthernetInfo{Source: "sysrepo", Speed: "1 Gb/s", Duplex: "Full", Autoneg: true}},
+		{"e2", &EthernetInfo{Source: "sysrepo", Speed: "100 Mb/s", Duplex: "Half"}},
+		{"e3", &EthernetInfo{Source: "sysrepo", Speed: "2500 Mb/s", Duplex: "Unknown", Autoneg: true}},
+		{"e4", &EthernetInfo{Source: "sysrepo", Speed: "Unknown", Duplex: "Unknown", Autoneg: true}},
+		{"br0", nil},
+		{"e9", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOperEthernet(data, tt.ifname)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: parseOperEthernet() = %+v, want an error", tt.ifname, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseOperEthernet() = %+v, %v, want %+v", tt.ifname, got, err, tt.want)
+		}
+	}
+}
@@ -17,6 +17,14 @@ func factoryResetExecuteHandler(w http.ResponseWriter, r *http.Request) {
 	username := getUsername(r)
 	log.Printf("Factory reset requested by user: %s", username)
 
+	op, err := beginOperation("Factory reset", username)
+	if err != nil {
+		log.Printf("Refusing factory reset: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	op.SetCancelable(false)
+
 	// Set headers to prevent caching
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -37,6 +45,7 @@ func factoryResetExecuteHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Simulate the reboot
 		log.Printf("Simulating reboot after factory reset")
+		op.End()
 
 		// In a real environment, you would trigger the sysrepo RPC here:
 		// resetErr := sysrepo.ExecuteRPC("factory-reset")
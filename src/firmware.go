@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Firmware bundle sources
+const (
+	sourceUpload = "upload" // Uploaded from the browser
+	sourceURL    = "url"    // Streamed by RAUC from an HTTP(S) server
+	sourceLocal  = "local"  // Already on the device, e.g., a USB stick
+)
+
+// FirmwareSource describes where a bundle to install comes from
+type FirmwareSource struct {
+	Type   string `json:"type"`
+	Path   string `json:"path"`             // File system path, or URL
+	Name   string `json:"name"`             // Bundle file name, for display
+	SHA256 string `json:"sha256,omitempty"` // Of uploaded bundles
+}
+
+// LocalBundle is a bundle found on local or removable storage
+type LocalBundle struct {
+	Path     string
+	Name     string
+	Size     string
+	Modified string
+}
+
+var (
+	// Directories scanned for bundles, USB sticks are mounted in /media
+	firmwareDirs = []string{"/media", "/mnt", "/var"}
+	// How many levels below each of firmwareDirs to look
+	firmwareScanDepth = 2
+	// Client used to check that a bundle URL is reachable
+	firmwareHTTPClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// String returns a human readable description of the source
+func (src FirmwareSource) String() string {
+	if src.Type == sourceUpload {
+		return src.Name
+	}
+	return src.Path
+}
+
+// newFirmwareSource creates a source from the type and location given
+// by the user, the bundle name is derived from the location
+func newFirmwareSource(typ, location string) FirmwareSource {
+	src := FirmwareSource{
+		Type: typ,
+		Path: strings.TrimSpace(location),
+	}
+
+	switch typ {
+	case sourceURL:
+		if u, err := url.Parse(src.Path); err == nil {
+			src.Name = path.Base(u.Path)
+		}
+	default:
+		src.Name = filepath.Base(src.Path)
+	}
+
+	return src
+}
+
+// validateFirmwareSource performs the same basic checks on a bundle
+// regardless of where it comes from
+func validateFirmwareSource(src FirmwareSource) error {
+	if !strings.HasSuffix(strings.ToLower(src.Name), ".pkg") {
+		return fmt.Errorf("invalid firmware file, must have .pkg extension")
+	}
+
+	switch src.Type {
+	case sourceUpload:
+		return checkFirmwareFile(src.Path)
+
+	case sourceURL:
+		return checkFirmwareURL(src.Path)
+
+	case sourceLocal:
+		for _, bundle := range scanFirmwareDirs() {
+			if bundle.Path == src.Path {
+				return checkFirmwareFile(src.Path)
+			}
+		}
+		return fmt.Errorf("%s is not an available bundle", src.Path)
+	}
+
+	return fmt.Errorf("unknown firmware source %q", src.Type)
+}
+
+// checkFirmwareFile verifies that a bundle on disk is a non-empty file
+func checkFirmwareFile(fn string) error {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return fmt.Errorf("firmware file not found")
+	}
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return fmt.Errorf("%s is not a valid firmware file", filepath.Base(fn))
+	}
+
+	return nil
+}
+
+// checkFirmwareURL verifies that a bundle URL is reachable, RAUC will
+// do the actual download when installing
+func checkFirmwareURL(location string) error {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid firmware URL, must be http:// or https://")
+	}
+
+	resp, err := firmwareHTTPClient.Head(location)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = firmwareHTTPClient.Get(location)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot reach firmware URL: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cannot fetch firmware URL: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// scanFirmwareDirs lists bundles on local and removable storage
+func scanFirmwareDirs() []LocalBundle {
+	var bundles []LocalBundle
+
+	for _, dir := range firmwareDirs {
+		base := strings.Count(filepath.Clean(dir), string(filepath.Separator))
+
+		filepath.WalkDir(dir, func(fn string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable or missing directory, skip it
+				return fs.SkipDir
+			}
+
+			depth := strings.Count(fn, string(filepath.Separator)) - base
+			if d.IsDir() {
+				if depth >= firmwareScanDepth {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() || !strings.HasSuffix(strings.ToLower(d.Name()), ".pkg") {
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			bundles = append(bundles, LocalBundle{
+				Path:     fn,
+				Name:     d.Name(),
+				Size:     formatSize(uint64(fi.Size())),
+				Modified: fi.ModTime().Format("2006-01-02 15:04:05"),
+			})
+			return nil
+		})
+	}
+
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].Path < bundles[j].Path
+	})
+
+	return bundles
+}
+
+// BundleInfo holds what `rauc info` tells about a bundle
+type BundleInfo struct {
+	Compatible       string        `json:"compatible"`
+	Version          string        `json:"version"`
+	Description      string        `json:"description"`
+	Build            string        `json:"build"`
+	Hash             string        `json:"hash"`
+	Images           []BundleImage `json:"images"`
+	Verified         bool          `json:"verified"`
+	SignatureError   string        `json:"signature_error,omitempty"`
+	SystemCompatible string        `json:"system_compatible"`
+	CompatibleOK     bool          `json:"compatible_ok"`
+}
+
+// BundleImage is an image in a bundle and the slot class it targets
+type BundleImage struct {
+	SlotClass string `json:"slot_class"`
+	Variant   string `json:"variant,omitempty"`
+	Filename  string `json:"filename"`
+	Checksum  string `json:"checksum"`
+	Size      int64  `json:"size"`
+}
+
+// raucSystemConf is where RAUC keeps the compatible string of the system
+var raucSystemConf = "/etc/rauc/system.conf"
+
+// inspectBundle runs `rauc info` on a bundle, which also verifies its
+// signature.  A bundle with a bad signature is inspected once more,
+// without verification, so the user can see what it contains.
+func inspectBundle(location string) (*BundleInfo, error) {
+	verified := true
+	signatureError := ""
+
+	output, err := exec.Command("rauc", "info", "--output-format=json", location).Output()
+	if err != nil {
+		msg := err.Error()
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			msg = strings.TrimSpace(string(exitErr.Stderr))
+		}
+
+		output, err = exec.Command("rauc", "info", "--no-verify", "--output-format=json", location).Output()
+		if err != nil {
+			return nil, fmt.Errorf("rauc info failed: %s", msg)
+		}
+
+		verified = false
+		signatureError = msg
+	}
+
+	info, err := parseRaucInfo(output)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Verified = verified
+	info.SignatureError = signatureError
+	info.SystemCompatible = getSystemCompatible()
+	info.CompatibleOK = info.Compatible != "" && info.Compatible == info.SystemCompatible
+
+	return info, nil
+}
+
+// parseRaucInfo parses the JSON output of `rauc info`.  Older versions
+// of RAUC list each image as an object keyed by its slot class, newer
+// ones have the slot class as a member of the image.
+func parseRaucInfo(data []byte) (*BundleInfo, error) {
+	var raw struct {
+		Compatible  string            `json:"compatible"`
+		Version     string            `json:"version"`
+		Description string            `json:"description"`
+		Build       string            `json:"build"`
+		Hash        string            `json:"hash"`
+		Images      []json.RawMessage `json:"images"`
+	}
+
+	type image struct {
+		SlotClass string `json:"slot-class"`
+		Variant   string `json:"variant"`
+		Filename  string `json:"filename"`
+		Checksum  string `json:"checksum"`
+		Size      int64  `json:"size"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse rauc info output: %w", err)
+	}
+
+	info := &BundleInfo{
+		Compatible:  raw.Compatible,
+		Version:     raw.Version,
+		Description: raw.Description,
+		Build:       raw.Build,
+		Hash:        raw.Hash,
+	}
+
+	for _, msg := range raw.Images {
+		var img image
+		if err := json.Unmarshal(msg, &img); err == nil && img.SlotClass != "" {
+			info.Images = append(info.Images, BundleImage(img))
+			continue
+		}
+
+		var keyed map[string]image
+		if err := json.Unmarshal(msg, &keyed); err != nil {
+			return nil, fmt.Errorf("failed to parse rauc info image: %w", err)
+		}
+		for class, img := range keyed {
+			img.SlotClass = class
+			info.Images = append(info.Images, BundleImage(img))
+		}
+	}
+
+	return info, nil
+}
+
+// getSystemCompatible returns the RAUC compatible string of the system
+func getSystemCompatible() string {
+	file, err := os.Open(raucSystemConf)
+	if err != nil {
+		log.Printf("Failed opening %s: %v", raucSystemConf, err)
+		return ""
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "system" && strings.TrimSpace(key) == "compatible" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// checkBundle refuses bundles that are not signed with a trusted key,
+// or that are meant for another type of system
+func checkBundle(info *BundleInfo) error {
+	if !info.Verified {
+		return fmt.Errorf("signature verification failed: %s", info.SignatureError)
+	}
+	if !info.CompatibleOK {
+		return fmt.Errorf("bundle is for %q, this system is %q", info.Compatible, info.SystemCompatible)
+	}
+
+	return nil
+}
+
+// inspectFirmwareHandler inspects a bundle before installation, the
+// bundle is given the same way as when installing it
+func inspectFirmwareHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	var src FirmwareSource
+	switch r.FormValue("source") {
+	case "", sourceUpload:
+		up, err := completedUpload(r.FormValue("upload"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid firmware upload: %v", err), http.StatusBadRequest)
+			return
+		}
+		src = FirmwareSource{Type: sourceUpload, Path: uploadPath(up.ID), Name: up.Name}
+
+	case sourceURL:
+		src = newFirmwareSource(sourceURL, r.FormValue("url"))
+
+	case sourceLocal:
+		src = newFirmwareSource(sourceLocal, r.FormValue("path"))
+
+	default:
+		http.Error(w, "Unknown firmware source", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateFirmwareSource(src); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid firmware: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var info *BundleInfo
+	var err error
+	if _, err = exec.LookPath("rauc"); err == nil {
+		info, err = inspectBundle(src.Path)
+	} else if debug {
+		// Same as startUpgradeProcess, simulate in debug mode
+		info = &BundleInfo{
+			Description:  "RAUC not available, inspection simulated",
+			Verified:     true,
+			CompatibleOK: true,
+		}
+	} else {
+		http.Error(w, "Bundle inspection requires RAUC, which is not available", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Error inspecting firmware %s: %v", src, err)
+		http.Error(w, fmt.Sprintf("Failed to inspect firmware: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	log.Printf("Inspected firmware %s: compatible %s, version %s, verified %t",
+		src, info.Compatible, info.Version, info.Verified)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckFirmwareURL(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/infix-x86_64.pkg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+	})
+	mux.HandleFunc("/get-only/infix-x86_64.pkg", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("bundle"))
+	})
+	mux.HandleFunc("/get-only/missing.pkg", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/latest.pkg", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/infix-x86_64.pkg", http.StatusFound)
+	})
+	mux.HandleFunc("/moved.pkg", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing.pkg", http.StatusMovedPermanently)
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		err      string
+		requests []string
+	}{
+		{
+			name:     "HEAD",
+			path:     "/infix-x86_64.pkg",
+			requests: []string{"HEAD /infix-x86_64.pkg"},
+		},
+		{
+			name:     "GET when HEAD is not allowed",
+			path:     "/get-only/infix-x86_64.pkg",
+			requests: []string{"HEAD /get-only/infix-x86_64.pkg", "GET /get-only/infix-x86_64.pkg"},
+		},
+		{
+			name:     "Not found",
+			path:     "/missing.pkg",
+			err:      "404 Not Found",
+			requests: []string{"HEAD /missing.pkg"},
+		},
+		{
+			name:     "Not found by GET",
+			path:     "/get-only/missing.pkg",
+			err:      "404 Not Found",
+			requests: []string{"HEAD /get-only/missing.pkg", "GET /get-only/missing.pkg"},
+		},
+		{
+			name:     "Redirect",
+			path:     "/latest.pkg",
+			requests: []string{"HEAD /latest.pkg", "HEAD /infix-x86_64.pkg"},
+		},
+		{
+			name:     "Redirect to not found",
+			path:     "/moved.pkg",
+			err:      "404 Not Found",
+			requests: []string{"HEAD /moved.pkg", "HEAD /missing.pkg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+
+			src := newFirmwareSource(sourceURL, srv.URL+tt.path)
+			err := validateFirmwareSource(src)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("validateFirmwareSource(%s) = %v", src, err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("validateFirmwareSource(%s) = %v, want %q", src, err, tt.err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(requests, ", ") != strings.Join(tt.requests, ", ") {
+				t.Errorf("requests = %q, want %q", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestCheckFirmwareURLInvalid(t *testing.T) {
+	for _, location := range []string{
+		"ftp://example.com/infix.pkg",
+		"file:///tmp/infix.pkg",
+		"http:///infix.pkg",
+		"infix.pkg",
+	} {
+		if err := checkFirmwareURL(location); err == nil {
+			t.Errorf("checkFirmwareURL(%q) = nil, want an error", location)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// How often a followed log file is checked for new lines
+	followInterval = 500 * time.Millisecond
+	// Comment sent to keep idle connections, and proxies, alive
+	followKeepalive = 15 * time.Second
+	// Longest line sent as is, longer lines are split
+	maxFollowLine = 64 * 1024
+)
+
+// logFollower reads lines appended to a log file.  It copes with the
+// file being truncated, and with it being renamed and recreated by a
+// log rotation, by polling.
+type logFollower struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+}
+
+// newLogFollower follows path, starting at offset, which is clamped
+// to the size of the file
+func newLogFollower(path string, offset int64) (*logFollower, error) {
+	f := &logFollower{path: path}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || offset > f.info.Size() {
+		offset = f.info.Size()
+	}
+	if _, err := f.file.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.offset = offset
+
+	return f, nil
+}
+
+// open (re)opens the followed file from the start
+func (f *logFollower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.info = info
+	f.offset = 0
+
+	return nil
+}
+
+// Close stops following the file
+func (f *logFollower) Close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// followEvent is a batch of lines, or a notice, sent to the browser
+type followEvent struct {
+	Event string
+	Lines []string
+}
+
+// Poll returns complete lines added since the last poll, and a notice
+// if the file was truncated or rotated
+func (f *logFollower) Poll() ([]followEvent, error) {
+	var events []followEvent
+
+	// Drain what is left of the current file, even if it has been
+	// rotated, new lines may have been written before the rename
+	lines, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		events = append(events, followEvent{Lines: lines})
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// Rotated, but not yet recreated, try again next poll
+		return events, nil
+	}
+
+	var notice string
+	switch {
+	case !os.SameFile(info, f.info):
+		// The last line of the old file is as complete as it gets
+		if len(f.partial) > 0 {
+			events = append(events, followEvent{Lines: []string{string(f.partial)}})
+		}
+		if err := f.open(); err != nil {
+			return events, nil
+		}
+		notice = "Log file rotated"
+
+	case info.Size() < f.offset:
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return events, err
+		}
+		f.offset = 0
+		notice = "Log file truncated"
+
+	default:
+		return events, nil
+	}
+
+	f.partial = nil
+	events = append(events, followEvent{Event: "notice", Lines: []string{notice}})
+
+	lines, err = f.read()
+	if len(lines) > 0 {
+		events = append(events, followEvent{Lines: lines})
+	}
+
+	return events, err
+}
+
+// read returns complete lines from the current position, incomplete
+// lines are kept until the rest has been written
+func (f *logFollower) read() ([]string, error) {
+	buf, err := io.ReadAll(f.file)
+	if err != nil {
+		return nil, err
+	}
+	f.offset += int64(len(buf))
+
+	data := append(f.partial, buf...)
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		if len(data) < maxFollowLine {
+			f.partial = data
+			return nil, nil
+		}
+
+		f.partial = nil
+		return []string{string(data)}, nil
+	}
+
+	lines := strings.Split(string(data[:end]), "\n")
+	f.partial = append([]byte(nil), data[end+1:]...)
+
+	return lines, nil
+}
+
+// filterLines returns the lines matching the filter
+func filterLines(filter *LogFilter, lines []string) []string {
+	var matching []string
+
+	now := time.Now()
+	for _, line := range lines {
+		if filter.Match(line, now) {
+			matching = append(matching, line)
+		}
+	}
+
+	return matching
+}
+
+// followEntry is a followed line, parsed for the log table
+type followEntry struct {
+	Time     string `json:"time,omitempty"`
+	Host     string `json:"host,omitempty"`
+	App      string `json:"app,omitempty"`
+	PID      string `json:"pid,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Badge    string `json:"badge,omitempty"`
+	Row      string `json:"row,omitempty"`
+	Message  string `json:"message"`
+	Raw      bool   `json:"raw,omitempty"`
+}
+
+// encodeLines returns the lines parsed for the log table, as JSON, one
+// line each
+func encodeLines(lines []string) []string {
+	encoded := make([]string, 0, len(lines))
+
+	now := time.Now()
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		fe := followEntry{Message: line, Raw: true}
+		if entry, ok := parseSyslogLine(line, now); ok {
+			fe = followEntry{
+				Time:     entry.TimeString(),
+				Host:     entry.Host,
+				App:      entry.App,
+				PID:      entry.PID,
+				Severity: entry.SeverityName(),
+				Badge:    entry.SeverityClass(),
+				Row:      entry.RowClass(),
+				Message:  entry.Message,
+			}
+		}
+
+		data, err := json.Marshal(fe)
+		if err != nil {
+			continue
+		}
+		encoded = append(encoded, string(data))
+	}
+
+	return encoded
+}
+
+// writeEvent sends a Server-Sent Event, each line as a data field
+func writeEvent(w io.Writer, event string, lines []string) {
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(w, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// followLogHandler streams lines appended to a log file as
+// Server-Sent Events, starting at the given offset, usually the size
+// of the file when the page was rendered.  With format=json, lines
+// are sent parsed, for the log table.
+func followLogHandler(w http.ResponseWriter, r *http.Request) {
+	filename := r.URL.Query().Get("file")
+	if filename == "" {
+		http.Error(w, "Log filename is required", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the filename to prevent directory traversal
+	if strings.Contains(filename, "..") || strings.Contains(filename, "/") {
+		http.Error(w, "Invalid log filename", http.StatusBadRequest)
+		return
+	}
+
+	if strings.HasSuffix(filename, ".gz") {
+		http.Error(w, "Compressed log files cannot be followed", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseLogFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	structured := r.URL.Query().Get("format") == "json"
+
+	// A reconnecting EventSource continues where it left off
+	offset := int64(-1)
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("offset")
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		offset = n
+	}
+
+	follower, err := newLogFollower(filepath.Join("/var/log", filename), offset)
+	if err != nil {
+		log.Printf("Error following log file %s: %v", filename, err)
+		http.Error(w, "Failed to open log file", http.StatusNotFound)
+		return
+	}
+	defer follower.Close()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	poll := time.NewTicker(followInterval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(followKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+
+		case <-poll.C:
+			events, err := follower.Poll()
+			for _, ev := range events {
+				if ev.Event == "" && filter.Active() {
+					ev.Lines = filterLines(filter, ev.Lines)
+					if len(ev.Lines) == 0 {
+						continue
+					}
+				}
+				if ev.Event == "" && structured {
+					ev.Lines = encodeLines(ev.Lines)
+				}
+				writeEvent(w, ev.Event, ev.Lines)
+			}
+			if err != nil {
+				log.Printf("Error following log file %s: %v", filename, err)
+				writeEvent(w, "error", []string{"Failed to read log file"})
+				rc.Flush()
+				return
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			// Position of the last complete line, for reconnects
+			fmt.Fprintf(w, "id: %d\n\n", follower.offset-int64(len(follower.partial)))
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpgradeRecord is an entry in the persistent upgrade history
+type UpgradeRecord struct {
+	ID         string    `json:"id"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished,omitempty"`
+	User       string    `json:"user"`
+	RemoteAddr string    `json:"remote_addr"`
+	Source     string    `json:"source"`
+	Bundle     string    `json:"bundle"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	Slot       string    `json:"slot,omitempty"`
+	BootID     string    `json:"boot_id"`
+	FirstBoot  string    `json:"first_boot,omitempty"`
+	BootedSlot string    `json:"booted_slot,omitempty"`
+}
+
+// Upgrade outcomes
+const (
+	outcomeInstalling = "installing"
+	outcomeCompleted  = "completed"
+	outcomeFailed     = "failed"
+	outcomeAborted    = "aborted"
+)
+
+// Number of records kept in the history
+const maxHistory = 50
+
+var historyMutex sync.Mutex
+
+// historyPath returns the path to the upgrade history
+func historyPath() string {
+	return filepath.Join(statePath, "upgrade-history.json")
+}
+
+// getBootID returns the unique ID of the current boot
+func getBootID() string {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// remoteAddr returns the address of the client, as seen by the proxy
+// in front of us, see middleware.RealIP
+func remoteAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// loadHistory reads the upgrade history, oldest record first
+func loadHistory() ([]UpgradeRecord, error) {
+	var history []UpgradeRecord
+
+	data, err := os.ReadFile(historyPath())
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// saveHistory writes the upgrade history, atomically so that a power
+// loss cannot leave a truncated file behind
+func saveHistory(history []UpgradeRecord) error {
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := historyPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, historyPath())
+}
+
+// newUpgradeRecord adds a record of an upgrade about to start
+func newUpgradeRecord(user, addr string, src FirmwareSource) *UpgradeRecord {
+	id := make([]byte, 8)
+	io.ReadFull(rand.Reader, id)
+
+	rec := &UpgradeRecord{
+		ID:         hex.EncodeToString(id),
+		Started:    time.Now(),
+		User:       user,
+		RemoteAddr: addr,
+		Source:     src.Type,
+		Bundle:     src.String(),
+		SHA256:     src.SHA256,
+		Outcome:    outcomeInstalling,
+		BootID:     getBootID(),
+	}
+
+	saveUpgradeRecord(rec)
+	return rec
+}
+
+// saveUpgradeRecord adds or updates a record in the history
+func saveUpgradeRecord(rec *UpgradeRecord) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	history, err := loadHistory()
+	if err != nil {
+		log.Printf("Error reading upgrade history, starting over: %v", err)
+	}
+
+	found := false
+	for i := range history {
+		if history[i].ID == rec.ID {
+			history[i] = *rec
+			found = true
+		}
+	}
+	if !found {
+		history = append(history, *rec)
+	}
+
+	if err := saveHistory(history); err != nil {
+		log.Printf("Error saving upgrade history: %v", err)
+	}
+}
+
+// finishUpgradeRecord records the outcome of an upgrade, canceled
+// upgrades are aborted, not failed
+func finishUpgradeRecord(rec *UpgradeRecord, status UpgradeStatus) {
+	rec.Finished = time.Now()
+
+	switch {
+	case status.Status == "completed":
+		rec.Outcome = outcomeCompleted
+	case status.Canceled:
+		rec.Outcome = outcomeAborted
+		rec.Error = status.Message
+	default:
+		rec.Outcome = outcomeFailed
+		rec.Error = status.Error
+		if rec.Error == "" {
+			rec.Error = status.Message
+		}
+	}
+
+	saveUpgradeRecord(rec)
+}
+
+// checkFirstBoot is called at startup to detect the first boot after
+// a completed upgrade.  Upgrades interrupted by a reboot or a crash
+// are marked as aborted.
+func checkFirstBoot() {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	bootID := getBootID()
+	if bootID == "" {
+		return
+	}
+
+	history, err := loadHistory()
+	if err != nil || len(history) == 0 {
+		return
+	}
+
+	changed := false
+	for i := range history {
+		rec := &history[i]
+		if rec.BootID == bootID {
+			continue
+		}
+
+		if rec.Outcome == outcomeInstalling {
+			rec.Outcome = outcomeAborted
+			rec.Error = "Interrupted by reboot"
+			changed = true
+		}
+	}
+
+	// Only the most recent upgrade can be what we booted into
+	rec := &history[len(history)-1]
+	if rec.Outcome == outcomeCompleted && rec.FirstBoot == "" && rec.BootID != bootID {
+		rec.FirstBoot = bootID
+		if status, err := getRaucStatus(); err == nil {
+			rec.BootedSlot = status.Booted
+			if slot := status.BootedSlot(); slot != nil {
+				rec.BootedSlot = slot.Name
+			}
+		}
+		log.Printf("First boot after upgrade to %s, booted from slot %s", rec.Version, rec.BootedSlot)
+		changed = true
+	}
+
+	if changed {
+		if err := saveHistory(history); err != nil {
+			log.Printf("Error saving upgrade history: %v", err)
+		}
+	}
+}
+
+// getUpgradeHistory returns the upgrade history, most recent first,
+// and the upgrade this is the first boot after, if any
+func getUpgradeHistory() ([]UpgradeRecord, *UpgradeRecord) {
+	historyMutex.Lock()
+	history, err := loadHistory()
+	historyMutex.Unlock()
+	if err != nil {
+		log.Printf("Error reading upgrade history: %v", err)
+		return nil, nil
+	}
+
+	var firstBoot *UpgradeRecord
+	bootID := getBootID()
+	recent := make([]UpgradeRecord, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		recent = append(recent, history[i])
+		if bootID != "" && history[i].FirstBoot == bootID {
+			firstBoot = &recent[len(recent)-1]
+		}
+	}
+
+	return recent, firstBoot
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestFinishUpgradeRecord(t *testing.T) {
+	saved := statePath
+	statePath = t.TempDir()
+	defer func() { statePath = saved }()
+
+	tests := []struct {
+		name    string
+		status  UpgradeStatus
+		outcome string
+		err     string
+	}{
+		{
+			name:    "Completed",
+			status:  UpgradeStatus{Status: "completed", Message: "Installation completed successfully"},
+			outcome: outcomeCompleted,
+		},
+		{
+			name:    "Failed",
+			status:  UpgradeStatus{Status: "error", Message: "RAUC failed", Error: "Error: RAUC failed"},
+			outcome: outcomeFailed,
+			err:     "Error: RAUC failed",
+		},
+		{
+			name:    "Canceled",
+			status:  UpgradeStatus{Status: "error", Message: "Installation canceled", Error: "Error: Installation canceled", Canceled: true},
+			outcome: outcomeAborted,
+			err:     "Installation canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &UpgradeRecord{ID: tt.name, Outcome: outcomeInstalling}
+			finishUpgradeRecord(rec, tt.status)
+
+			if rec.Outcome != tt.outcome || rec.Error != tt.err {
+				t.Errorf("outcome, error = %q, %q, want %q, %q", rec.Outcome, rec.Error, tt.outcome, tt.err)
+			}
+			if rec.Finished.IsZero() {
+				t.Errorf("no finish time")
+			}
+		})
+	}
+}
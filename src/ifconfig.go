@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// errInvalid is returned by edits when a field is rejected by sysrepo
+var errInvalid = errors.New("invalid configuration")
+
+// InterfaceConfig is the configuration of an interface, as edited on
+// its details page.  Addresses are one per line, in CIDR notation.
+type InterfaceConfig struct {
+	Enabled     bool
+	Description string
+	MTU         string
+	Addresses   string
+	DHCP        bool
+	prefixes    []netip.Prefix
+	typ         string
+	configured  bool
+}
+
+// interfacePath returns the xpath of an interface
+func interfacePath(name string) string {
+	return fmt.Sprintf("%s/interface[name='%s']", interfacesXPath, name)
+}
+
+// addressPath returns the xpath of an address of an interface,
+// relative to the interface
+func addressPath(prefix netip.Prefix) string {
+	family := "ipv4"
+	if prefix.Addr().Is6() {
+		family = "ipv6"
+	}
+	return fmt.Sprintf("ietf-ip:%s/address[ip='%s']", family, prefix.Addr())
+}
+
+// getInterfaceConfig reads the configuration of an interface from the
+// running datastore.  Interfaces not configured, e.g., ports found at
+// boot, are enabled with no addresses.
+func getInterfaceConfig(name string) (*InterfaceConfig, error) {
+	cfg := &InterfaceConfig{Enabled: true}
+
+	running, err := getInterfacesJSON(sr.DSRunning)
+	if err != nil && !errors.Is(err, errNoData) {
+		return nil, err
+	}
+
+	if running != nil {
+		for _, i := range running.Interfaces.Interface {
+			if i.Name != name {
+				continue
+			}
+
+			cfg.configured = true
+			cfg.typ = i.Type
+			cfg.Description = i.Description
+			if i.Enabled != nil {
+				cfg.Enabled = *i.Enabled
+			}
+			if i.IPv4 != nil {
+				cfg.MTU = i.IPv4.MTU.String()
+				cfg.DHCP = i.IPv4.DHCP != nil
+			}
+
+			var lines []string
+			for _, a := range addresses(i.IPv4, i.IPv6) {
+				prefix, err := netip.ParsePrefix(a.String())
+				if err != nil {
+					continue
+				}
+				cfg.prefixes = append(cfg.prefixes, prefix)
+				lines = append(lines, prefix.String())
+			}
+			cfg.Addresses = strings.Join(lines, "\n")
+		}
+	}
+
+	// New list entries need the type, as detected by the system
+	if !cfg.configured {
+		oper, err := getInterfacesJSON(sr.DSOperational)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range oper.Interfaces.Interface {
+			if i.Name == name {
+				cfg.typ = i.Type
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseInterfaceForm reads and validates the configuration of an
+// interface from a form
+func parseInterfaceForm(r *http.Request) (*InterfaceConfig, map[string]string) {
+	cfg := &InterfaceConfig{
+		Enabled:     r.FormValue("enabled") == "on",
+		Description: strings.TrimSpace(r.FormValue("description")),
+		MTU:         strings.TrimSpace(r.FormValue("mtu")),
+		Addresses:   strings.TrimSpace(r.FormValue("addresses")),
+		DHCP:        r.FormValue("dhcp") == "on",
+	}
+	errs := make(map[string]string)
+
+	if cfg.MTU != "" {
+		if mtu, err := strconv.ParseUint(cfg.MTU, 10, 16); err != nil || mtu < 68 {
+			errs["mtu"] = "Must be a number from 68 to 65535"
+		}
+	}
+
+	seen := make(map[netip.Addr]bool)
+	for _, line := range strings.Fields(cfg.Addresses) {
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			errs["addresses"] = fmt.Sprintf("%s is not an address with a prefix length, e.g., 192.168.1.1/24", line)
+			break
+		}
+		if seen[prefix.Addr()] {
+			errs["addresses"] = fmt.Sprintf("%s is listed more than once", prefix.Addr())
+			break
+		}
+		seen[prefix.Addr()] = true
+		cfg.prefixes = append(cfg.prefixes, prefix)
+	}
+
+	return cfg, errs
+}
+
+// setInterfaceConfig changes the configuration of an interface, items
+// rejected by sysrepo, e.g., by YANG type validation, are reported as
+// problems of the field they were set from
+func setInterfaceConfig(sess *sr.Session, name string, old, cfg *InterfaceConfig, errs map[string]string) error {
+	base := interfacePath(name)
+
+	field := func(name string, err error) {
+		if err != nil && errs[name] == "" {
+			errs[name] = "Rejected: " + errorMessage(err)
+		}
+	}
+
+	if !old.configured {
+		field("enabled", sess.SetItem(base+"/type", &cfg.typ, sr.EditDefault))
+	}
+
+	field("enabled", setItems(sess, base, map[string]string{"enabled": strconv.FormatBool(cfg.Enabled)}))
+	field("description", setItems(sess, base, map[string]string{"description": cfg.Description}))
+	field("mtu", setItems(sess, base, map[string]string{"ietf-ip:ipv4/mtu": cfg.MTU}))
+
+	keep := make(map[netip.Addr]bool)
+	for _, prefix := range cfg.prefixes {
+		keep[prefix.Addr()] = true
+	}
+	for _, prefix := range old.prefixes {
+		if !keep[prefix.Addr()] {
+			field("addresses", sess.DeleteItem(base+"/"+addressPath(prefix), sr.EditDefault))
+		}
+	}
+	for _, prefix := range cfg.prefixes {
+		length := strconv.Itoa(prefix.Bits())
+		field("addresses", sess.SetItem(base+"/"+addressPath(prefix)+"/prefix-length", &length, sr.EditDefault))
+	}
+
+	dhcp := base + "/ietf-ip:ipv4/infix-dhcp-client:dhcp"
+	if cfg.DHCP {
+		field("dhcp", sess.SetItem(dhcp, nil, sr.EditDefault))
+	} else {
+		field("dhcp", sess.DeleteItem(dhcp, sr.EditDefault))
+	}
+
+	if len(errs) > 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+// interfaceFields are the form fields of the leaves set by
+// setInterfaceConfig, by their path relative to the interface
+var interfaceFields = []struct{ path, field string }{
+	{"/type", "enabled"},
+	{"/enabled", "enabled"},
+	{"/description", "description"},
+	{"/ietf-ip:ipv4/mtu", "mtu"},
+	{"/ietf-ip:ipv4/address", "addresses"},
+	{"/ietf-ip:ipv6/address", "addresses"},
+	{"/ietf-ip:ipv4/infix-dhcp-client:dhcp", "dhcp"},
+}
+
+// interfaceField returns the form field of a path in the configuration
+// of an interface, empty if the path is not of a field of the form
+func interfaceField(name, path string) string {
+	rest, ok := strings.CutPrefix(path, interfacePath(name))
+	if !ok {
+		return ""
+	}
+
+	for _, f := range interfaceFields {
+		if next, ok := strings.CutPrefix(rest, f.path); ok && (next == "" || next[0] == '/' || next[0] == '[') {
+			return f.field
+		}
+	}
+	return ""
+}
+
+// rejectedFields adds the errors sysrepo reported applying the
+// configuration of an interface to the form fields of their paths, it
+// returns false if any error is not of a field, e.g., has no path
+func rejectedFields(name string, err error, errs map[string]string) bool {
+	infos := sysrepoErrors(err)
+	if len(infos) == 0 {
+		return false
+	}
+
+	all := true
+	for _, info := range infos {
+		field := interfaceField(name, info.Path)
+		if field == "" {
+			all = false
+			continue
+		}
+		if errs[field] == "" {
+			errs[field] = "Rejected: " + info.Message
+		}
+	}
+	return all
+}
+
+// saveInterfaceHandler changes the configuration of an interface
+func saveInterfaceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "ifname")
+
+	info, err := getInterfaceInfo(name)
+	if err != nil {
+		log.Printf("Error getting network info: %v", err)
+		http.Error(w, "Failed to get network interfaces", http.StatusInternalServerError)
+		return
+	}
+	if info == nil {
+		http.Error(w, "No such interface", http.StatusNotFound)
+		return
+	}
+
+	old, err := getInterfaceConfig(name)
+	if err != nil {
+		log.Printf("Error reading configuration of %s: %v", name, err)
+		info.Error = "Failed to read the interface configuration"
+		renderPage(w, r, "iface", info)
+		return
+	}
+
+	cfg, errs := parseInterfaceForm(r)
+	cfg.typ = old.typ
+	if len(errs) == 0 {
+		err = editConfig(func(sess *sr.Session) error {
+			return setInterfaceConfig(sess, name, old, cfg, errs)
+		})
+	}
+
+	if len(errs) > 0 || err != nil {
+		// Show the form again, as entered, with the problems
+		if err != nil && !errors.Is(err, errInvalid) {
+			log.Printf("Error saving configuration of %s: %v", name, err)
+			if !rejectedFields(name, err, errs) {
+				info.Error = fmt.Sprintf("Failed to save %s: %s", name, errorMessage(err))
+			}
+		}
+		info.Config, info.Errors = cfg, errs
+		renderPage(w, r, "iface", info)
+		return
+	}
+
+	log.Printf("Configuration of %s applied by %s", name, getUsername(r))
+
+	info, err = getInterfaceInfo(name)
+	if err != nil || info == nil {
+		http.Error(w, "Failed to get network interfaces", http.StatusInternalServerError)
+		return
+	}
+	info.Message = fmt.Sprintf("Configuration of %s applied", name)
+	renderPage(w, r, "iface", info)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// applyError returns the error of ApplyChanges when sysrepo reports
+// errors for the session, the messages and paths as the binding splits
+// them, see splitPath in third_party/go-sysrepo
+func applyError(infos ...sr.ErrorInfo) error {
+	return sr.Error{Message: "Couldn't apply changes", Code: sr.ErrValidationFailed, Errors: infos}
+}
+
+func TestRejectedFields(t *testing.T) {
+	const e1 = "/ietf-interfaces:interfaces/interface[name='e1']"
+
+	tests := []struct {
+		name string
+		err  error
+		errs map[string]string
+		all  bool
+	}{
+		{
+			name: "mtu",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Value "70000" does not satisfy the range constraint.`,
+				Path:    e1 + "/ietf-ip:ipv4/mtu",
+			}),
+			errs: map[string]string{"mtu": `Rejected: Value "70000" does not satisfy the range constraint.`},
+			all:  true,
+		},
+		{
+			name: "addresses and dhcp",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Duplicate instance of "address".`,
+				Path:    e1 + "/ietf-ip:ipv6/address[ip='fd00::1']",
+			}, sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Must condition "../../type = 'ianaift:ethernetCsmacd'" not satisfied.`,
+				Path:    e1 + "/ietf-ip:ipv4/infix-dhcp-client:dhcp",
+			}),
+			errs: map[string]string{
+				"addresses": `Rejected: Duplicate instance of "address".`,
+				"dhcp":      `Rejected: Must condition "../../type = 'ianaift:ethernetCsmacd'" not satisfied.`,
+			},
+			all: true,
+		},
+		{
+			name: "type of a new interface",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Invalid identityref "infix-if-type:foo" value.`,
+				Path:    e1 + "/type",
+			}),
+			errs: map[string]string{"enabled": `Rejected: Invalid identityref "infix-if-type:foo" value.`},
+			all:  true,
+		},
+		{
+			name: "another interface",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Leafref "../name" of value "e1" points to a non-existing leaf.`,
+				Path:    "/ietf-interfaces:interfaces/interface[name='e10']/infix-interfaces:bridge-port/bridge",
+			}),
+			errs: map[string]string{},
+		},
+		{
+			name: "leaf not on the form, and one on it",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Invalid boolean value "yes".`,
+				Path:    e1 + "/ietf-ip:ipv4/forwarding",
+			}, sr.ErrorInfo{
+				Code:    sr.ErrValidationFailed,
+				Message: `Value "70000" does not satisfy the range constraint.`,
+				Path:    e1 + "/ietf-ip:ipv4/mtu",
+			}),
+			errs: map[string]string{"mtu": `Rejected: Value "70000" does not satisfy the range constraint.`},
+		},
+		{
+			name: "callback without a path",
+			err: applyError(sr.ErrorInfo{
+				Code:    sr.ErrCallbackFailed,
+				Message: "Operation failed",
+			}),
+			errs: map[string]string{},
+		},
+		{
+			name: "no errors reported",
+			err:  sr.Error{Message: "Couldn't apply changes", Code: sr.ErrValidationFailed},
+			errs: map[string]string{},
+		},
+		{
+			name: "not a sysrepo error",
+			err:  errors.New("failed to connect to sysrepo"),
+			errs: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := make(map[string]string)
+		all := rejectedFields("e1", fmt.Errorf("wrapped: %w", tt.err), errs)
+		if all != tt.all || !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s: rejectedFields() = %v, %v, want %v, %v", tt.name, all, errs, tt.all, tt.errs)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := applyError(
+		sr.ErrorInfo{Message: `Value "70000" does not satisfy the range constraint.`, Path: "/ietf-interfaces:interfaces"},
+		sr.ErrorInfo{Message: "Operation failed"},
+	)
+	if got, want := errorMessage(err), `Value "70000" does not satisfy the range constraint.; Operation failed`; got != want {
+		t.Errorf("errorMessage() = %q, want %q", got, want)
+	}
+
+	err = sr.Error{Message: "Couldn't apply changes", Code: sr.ErrTimeout}
+	if got, want := errorMessage(err), err.Error(); got != want {
+		t.Errorf("errorMessage() = %q, want %q", got, want)
+	}
+}
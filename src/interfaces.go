@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+const interfacesXPath = "/ietf-interfaces:interfaces"
+
+// ietfIPJSON is the addresses of an interface, ietf-ip
+type ietfIPJSON struct {
+	MTU     json.Number      `json:"mtu"`
+	DHCP    *json.RawMessage `json:"infix-dhcp-client:dhcp"`
+	Address []struct {
+		IP           string      `json:"ip"`
+		PrefixLength json.Number `json:"prefix-length"`
+		Origin       string      `json:"origin"`
+	} `json:"address"`
+}
+
+// interfaceJSON is the parts of an interface shown by the network page,
+// operational and configured
+type interfaceJSON struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Enabled     *bool           `json:"enabled"`
+	AdminStatus string          `json:"admin-status"`
+	OperStatus  string          `json:"oper-status"`
+	PhysAddress string          `json:"phys-address"`
+	IPv4        *ietfIPJSON     `json:"ietf-ip:ipv4"`
+	IPv6        *ietfIPJSON     `json:"ietf-ip:ipv6"`
+	Bridge      *bridgeVLANJSON `json:"infix-interfaces:bridge"`
+	BridgePort  *struct {
+		Bridge string      `json:"bridge"`
+		PVID   json.Number `json:"pvid"`
+	} `json:"infix-interfaces:bridge-port"`
+	VLAN *struct {
+		LowerLayerIf string `json:"lower-layer-if"`
+		ID           int    `json:"id"`
+	} `json:"infix-interfaces:vlan"`
+	Ethernet *ethernetJSON `json:"ieee802-ethernet-interface:ethernet"`
+}
+
+// ethernetJSON is the operational data of an Ethernet port,
+// ieee802-ethernet-interface.  The speed is a decimal64 in Gb/s.
+type ethernetJSON struct {
+	AutoNegotiation struct {
+		Enable *bool `json:"enable"`
+	} `json:"auto-negotiation"`
+	Speed  string `json:"speed"`
+	Duplex string `json:"duplex"`
+}
+
+// interfacesJSON is the interfaces model
+type interfacesJSON struct {
+	Interfaces struct {
+		Interface []interfaceJSON `json:"interface"`
+	} `json:"ietf-interfaces:interfaces"`
+}
+
+// identityName returns a YANG identity without its module prefix
+func identityName(identity string) string {
+	if _, name, ok := strings.Cut(identity, ":"); ok {
+		return name
+	}
+	return identity
+}
+
+// addresses returns the addresses of both families
+func addresses(ipv4, ipv6 *ietfIPJSON) []AddrInfo {
+	var addrs []AddrInfo
+
+	for _, ip := range []*ietfIPJSON{ipv4, ipv6} {
+		if ip == nil {
+			continue
+		}
+		for _, a := range ip.Address {
+			length, _ := a.PrefixLength.Int64()
+			addrs = append(addrs, AddrInfo{
+				Address:   a.IP,
+				PrefixLen: int(length),
+				Origin:    a.Origin,
+			})
+		}
+	}
+
+	return addrs
+}
+
+// getInterfacesJSON reads the interfaces from a datastore
+func getInterfacesJSON(ds sr.Datastore) (*interfacesJSON, error) {
+	data, err := getDataJSON(ds, interfacesXPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces interfacesJSON
+	if err := json.Unmarshal(data, &ifaces); err != nil {
+		return nil, fmt.Errorf("failed to parse interfaces: %w", err)
+	}
+
+	return &ifaces, nil
+}
+
+// getOperEthernet returns the link settings of an Ethernet port from
+// the operational datastore
+func getOperEthernet(ifname string) (*EthernetInfo, error) {
+	xpath := fmt.Sprintf("%s/interface[name='%s']/ieee802-ethernet-interface:ethernet", interfacesXPath, ifname)
+	data, err := getDataJSON(sr.DSOperational, xpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOperEthernet(data, ifname)
+}
+
+// parseOperEthernet returns the link settings of an Ethernet port in
+// operational data of the interfaces
+func parseOperEthernet(data []byte, ifname string) (*EthernetInfo, error) {
+	var ifaces interfacesJSON
+	if err := json.Unmarshal(data, &ifaces); err != nil {
+		return nil, fmt.Errorf("failed to parse interfaces: %w", err)
+	}
+
+	for _, i := range ifaces.Interfaces.Interface {
+		if i.Name != ifname || i.Ethernet == nil {
+			continue
+		}
+
+		eth := i.Ethernet
+		info := &EthernetInfo{Source: "sysrepo", Speed: "Unknown", Duplex: "Unknown"}
+		if eth.AutoNegotiation.Enable != nil {
+			info.Autoneg = *eth.AutoNegotiation.Enable
+		}
+		if gbps, err := strconv.ParseFloat(eth.Speed, 64); err == nil && gbps > 0 {
+			info.Speed = speedString(int64(math.Round(gbps * 1000)))
+		}
+		switch eth.Duplex {
+		case "full":
+			info.Duplex = "Full"
+		case "half":
+			info.Duplex = "Half"
+		}
+
+		return info, nil
+	}
+
+	return nil, errNoData
+}
+
+// getOperInterfaces returns the interfaces from the operational
+// datastore.  Addresses are marked as configured when they are in the
+// running configuration, configured addresses not in use are added as
+// inactive.
+func getOperInterfaces() ([]Interface, error) {
+	oper, err := getInterfacesJSON(sr.DSOperational)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a configuration, all addresses are learned
+	configured := make(map[string][]AddrInfo)
+	if running, err := getInterfacesJSON(sr.DSRunning); err == nil {
+		for _, i := range running.Interfaces.Interface {
+			configured[i.Name] = addresses(i.IPv4, i.IPv6)
+		}
+	}
+
+	var ifaces []Interface
+	for _, i := range oper.Interfaces.Interface {
+		iface := Interface{
+			Name:        i.Name,
+			Type:        identityName(i.Type),
+			Description: i.Description,
+			AdminStatus: i.AdminStatus,
+			State:       strings.ToUpper(i.OperStatus),
+			HWAddr:      i.PhysAddress,
+		}
+		if i.BridgePort != nil {
+			iface.Master = i.BridgePort.Bridge
+		}
+		if i.VLAN != nil {
+			iface.Lower = i.VLAN.LowerLayerIf
+			iface.VID = i.VLAN.ID
+		}
+
+		conf := make(map[string]bool)
+		for _, c := range configured[i.Name] {
+			conf[c.String()] = true
+		}
+
+		active := make(map[string]bool)
+		for _, a := range addresses(i.IPv4, i.IPv6) {
+			a.Configured = conf[a.String()]
+			active[a.String()] = true
+			iface.Addresses = append(iface.Addresses, a)
+		}
+		for _, c := range configured[i.Name] {
+			if !active[c.String()] {
+				c.Configured, c.Inactive = true, true
+				iface.Addresses = append(iface.Addresses, c)
+			}
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	sortInterfaces(ifaces)
+	return ifaces, nil
+}
+
+// getInterfaces returns the interfaces from sysrepo, or from the
+// kernel if sysrepo is not available.  The source is returned with
+// them.
+func getInterfaces() ([]Interface, string, error) {
+	ifaces, err := getOperInterfaces()
+	if err == nil {
+		return ifaces, "sysrepo", nil
+	}
+	log.Printf("Interfaces from sysrepo unavailable, using netlink: %v", err)
+
+	ifaces, err = getNetworkInterfaces()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ifaces, "netlink", nil
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// Syslog configuration, ietf-syslog with the Infix augments
+const syslogXPath = "/ietf-syslog:syslog"
+
+// SyslogRemote is a remote syslog server logged to
+type SyslogRemote struct {
+	Name      string
+	Protocol  string
+	Address   string
+	Port      string
+	Selectors string
+	Format    string
+
+	filters []syslogFilter
+}
+
+// SyslogLogFile is a local log file
+type SyslogLogFile struct {
+	Name          string
+	Selectors     string
+	Format        string
+	NumberOfFiles string
+	MaxFileSize   string
+
+	filters []syslogFilter
+}
+
+// SyslogRotation is the default rotation of the log files
+type SyslogRotation struct {
+	NumberOfFiles string
+	MaxFileSize   string
+}
+
+// LogConfigInfo is the log settings page
+type LogConfigInfo struct {
+	Remotes    []SyslogRemote
+	Files      []SyslogLogFile
+	Rotation   SyslogRotation
+	NewRemote  SyslogRemote
+	NewFile    SyslogLogFile
+	Form       string
+	Errors     map[string]string
+	Message    string
+	Error      string
+	Facilities []string
+	Severities []string
+}
+
+// LogConfigForm is a remote server, or log file, form of the page,
+// with the problems found when it was submitted
+type LogConfigForm struct {
+	ID     string
+	New    bool
+	Remote SyslogRemote
+	File   SyslogLogFile
+	Errors map[string]string
+}
+
+// Formats returns the log formats, for the form
+func (f LogConfigForm) Formats() []string {
+	return syslogFormats
+}
+
+// Log formats of Infix, the default depends on the action
+var syslogFormats = []string{"bsd", "rfc3164", "rfc5424"}
+
+// syslogFilter is an entry of a facility-list, selecting messages of a
+// facility of at least a severity
+type syslogFilter struct {
+	Facility string `json:"facility"`
+	Severity string `json:"severity"`
+}
+
+// facilityFilterJSON is the facility filter of an action
+type facilityFilterJSON struct {
+	FacilityList []syslogFilter `json:"facility-list"`
+}
+
+// Largest size of a log file before rotation, max-file-size is a
+// uint32, in kilobytes on Infix, both for a file and by default
+const maxLogFileSize = math.MaxUint32
+
+// syslogJSON is the parts of the syslog model handled by the page
+type syslogJSON struct {
+	Syslog struct {
+		Actions struct {
+			File struct {
+				LogFile []struct {
+					Name           string             `json:"name"`
+					FacilityFilter facilityFilterJSON `json:"facility-filter"`
+					Format         string             `json:"infix-syslog:log-format"`
+					FileRotation   struct {
+						NumberOfFiles json.Number `json:"number-of-files"`
+						MaxFileSize   json.Number `json:"max-file-size"`
+					} `json:"file-rotation"`
+				} `json:"log-file"`
+			} `json:"file"`
+			Remote struct {
+				Destination []struct {
+					Name string `json:"name"`
+					UDP  *struct {
+						Address string      `json:"address"`
+						Port    json.Number `json:"port"`
+					} `json:"udp"`
+					TLS *struct {
+						Address string      `json:"address"`
+						Port    json.Number `json:"port"`
+					} `json:"tls"`
+					FacilityFilter facilityFilterJSON `json:"facility-filter"`
+					Format         string             `json:"infix-syslog:log-format"`
+				} `json:"destination"`
+			} `json:"remote"`
+		} `json:"actions"`
+		FileRotation struct {
+			NumberOfFiles json.Number `json:"number-of-files"`
+			MaxFileSize   json.Number `json:"max-file-size"`
+		} `json:"infix-syslog:file-rotation"`
+	} `json:"ietf-syslog:syslog"`
+}
+
+// Severities of ietf-syslog, most severe first
+var syslogSeverities = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+// Severity names as used in syslog.conf, and by the log viewer
+var severityAliases = map[string]string{
+	"emerg": "emergency",
+	"crit":  "critical",
+	"err":   "error",
+	"warn":  "warning",
+	"*":     "all",
+}
+
+// Names of remotes and log files, also used in xpath predicates
+var syslogName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Log file names, file:name is /var/log/name
+var syslogFileName = regexp.MustCompile(`^file:(/var/log/)?[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Host names, for remote servers given by name
+var hostName = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,62}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,62}[A-Za-z0-9])?)*\.?$`)
+
+// stripModule returns an identity without its module prefix, if it is
+// an ietf-syslog or infix-syslog identity
+func stripModule(identity string) string {
+	for _, prefix := range []string{"ietf-syslog:", "infix-syslog:"} {
+		if name, ok := strings.CutPrefix(identity, prefix); ok {
+			return name
+		}
+	}
+	return identity
+}
+
+// facilityIdentity returns the identity of a facility, those not in
+// ietf-syslog, see facilityNames, are Infix's
+func facilityIdentity(name string) string {
+	if name == "all" || strings.Contains(name, ":") {
+		return name
+	}
+	if slices.Contains(facilityNames, name) {
+		return "ietf-syslog:" + name
+	}
+	return "infix-syslog:" + name
+}
+
+// formatSelectors returns a facility list in syslog.conf style, e.g.,
+// "*.info, kern.debug"
+func formatSelectors(filters []syslogFilter) string {
+	var selectors []string
+	for _, f := range filters {
+		facility := stripModule(f.Facility)
+		if facility == "all" {
+			facility = "*"
+		}
+		severity := f.Severity
+		if severity == "all" {
+			severity = "*"
+		}
+		selectors = append(selectors, facility+"."+severity)
+	}
+
+	return strings.Join(selectors, ", ")
+}
+
+// parseSelectors parses a facility list in syslog.conf style
+func parseSelectors(s string) ([]syslogFilter, error) {
+	var filters []syslogFilter
+
+	for _, selector := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		facility, severity, ok := strings.Cut(selector, ".")
+		if !ok || facility == "" || severity == "" {
+			return nil, fmt.Errorf("%q is not facility.severity", selector)
+		}
+
+		if facility == "*" {
+			facility = "all"
+		} else if !syslogName.MatchString(facility) {
+			return nil, fmt.Errorf("invalid facility %q", facility)
+		}
+
+		severity = strings.ToLower(severity)
+		if alias, ok := severityAliases[severity]; ok {
+			severity = alias
+		}
+		if severity != "all" && severity != "none" && !slices.Contains(syslogSeverities, severity) {
+			return nil, fmt.Errorf("invalid severity %q", severity)
+		}
+
+		filters = append(filters, syslogFilter{Facility: facilityIdentity(facility), Severity: severity})
+	}
+
+	return filters, nil
+}
+
+// getSyslogConfig reads the syslog configuration from the running
+// datastore
+func getSyslogConfig() (*LogConfigInfo, error) {
+	info := &LogConfigInfo{}
+
+	data, err := getDataJSON(sr.DSRunning, syslogXPath)
+	if errors.Is(err, errNoData) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg syslogJSON
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse syslog configuration: %w", err)
+	}
+	syslog := cfg.Syslog
+
+	for _, d := range syslog.Actions.Remote.Destination {
+		remote := SyslogRemote{
+			Name:      d.Name,
+			Selectors: formatSelectors(d.FacilityFilter.FacilityList),
+			Format:    stripModule(d.Format),
+			filters:   d.FacilityFilter.FacilityList,
+		}
+		if d.UDP != nil {
+			remote.Protocol, remote.Address, remote.Port = "udp", d.UDP.Address, d.UDP.Port.String()
+		}
+		if d.TLS != nil {
+			remote.Protocol, remote.Address, remote.Port = "tls", d.TLS.Address, d.TLS.Port.String()
+		}
+		info.Remotes = append(info.Remotes, remote)
+	}
+
+	for _, f := range syslog.Actions.File.LogFile {
+		info.Files = append(info.Files, SyslogLogFile{
+			Name:          f.Name,
+			Selectors:     formatSelectors(f.FacilityFilter.FacilityList),
+			Format:        stripModule(f.Format),
+			NumberOfFiles: f.FileRotation.NumberOfFiles.String(),
+			MaxFileSize:   f.FileRotation.MaxFileSize.String(),
+			filters:       f.FacilityFilter.FacilityList,
+		})
+	}
+
+	info.Rotation = SyslogRotation{
+		NumberOfFiles: syslog.FileRotation.NumberOfFiles.String(),
+		MaxFileSize:   syslog.FileRotation.MaxFileSize.String(),
+	}
+
+	return info, nil
+}
+
+// validateNumber checks an optional unsigned number
+func validateNumber(errs map[string]string, field, value string, min, max uint64) {
+	validateQuantity(errs, field, value, min, max, "")
+}
+
+// validateQuantity checks an optional unsigned number of a unit, e.g.,
+// kB
+func validateQuantity(errs map[string]string, field, value string, min, max uint64, unit string) {
+	if value == "" {
+		return
+	}
+
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err == nil && n >= min && n <= max {
+		return
+	}
+
+	if unit == "" {
+		errs[field] = fmt.Sprintf("Must be a number from %d to %d", min, max)
+	} else {
+		errs[field] = fmt.Sprintf("Must be a number of %s from %d to %d", unit, min, max)
+	}
+}
+
+// validateFormat checks an optional log format
+func validateFormat(errs map[string]string, value string) {
+	if value != "" && !slices.Contains(syslogFormats, value) {
+		errs["format"] = "Unknown log format"
+	}
+}
+
+// parseRemoteForm reads and validates a remote server from a form
+func parseRemoteForm(r *http.Request) (SyslogRemote, map[string]string) {
+	remote := SyslogRemote{
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Protocol:  r.FormValue("protocol"),
+		Address:   strings.TrimSpace(r.FormValue("address")),
+		Port:      strings.TrimSpace(r.FormValue("port")),
+		Selectors: strings.TrimSpace(r.FormValue("selectors")),
+		Format:    r.FormValue("format"),
+	}
+	errs := make(map[string]string)
+
+	if !syslogName.MatchString(remote.Name) {
+		errs["name"] = "Letters, digits, '.', '_' and '-' only"
+	}
+	if remote.Protocol != "udp" && remote.Protocol != "tls" {
+		errs["protocol"] = "Must be UDP or TLS"
+	}
+	if net.ParseIP(remote.Address) == nil && !hostName.MatchString(remote.Address) {
+		errs["address"] = "Must be an IP address or a host name"
+	}
+	validateNumber(errs, "port", remote.Port, 1, 65535)
+	validateFormat(errs, remote.Format)
+
+	filters, err := parseSelectors(remote.Selectors)
+	if err != nil {
+		errs["selectors"] = "Invalid selectors: " + err.Error()
+	} else if len(filters) == 0 {
+		errs["selectors"] = "At least one selector, e.g., *.info, is required"
+	}
+	remote.filters = filters
+
+	return remote, errs
+}
+
+// parseFileForm reads and validates a log file from a form
+func parseFileForm(r *http.Request) (SyslogLogFile, map[string]string) {
+	file := SyslogLogFile{
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		Selectors:     strings.TrimSpace(r.FormValue("selectors")),
+		Format:        r.FormValue("format"),
+		NumberOfFiles: strings.TrimSpace(r.FormValue("number-of-files")),
+		MaxFileSize:   strings.TrimSpace(r.FormValue("max-file-size")),
+	}
+	errs := make(map[string]string)
+
+	if file.Name != "" && !strings.HasPrefix(file.Name, "file:") {
+		file.Name = "file:" + file.Name
+	}
+	if !syslogFileName.MatchString(file.Name) {
+		errs["name"] = "A file name in /var/log, e.g., file:messages"
+	}
+	validateNumber(errs, "number-of-files", file.NumberOfFiles, 1, 100)
+	validateQuantity(errs, "max-file-size", file.MaxFileSize, 1, maxLogFileSize, "kB")
+	validateFormat(errs, file.Format)
+
+	filters, err := parseSelectors(file.Selectors)
+	if err != nil {
+		errs["selectors"] = "Invalid selectors: " + err.Error()
+	} else if len(filters) == 0 {
+		errs["selectors"] = "At least one selector, e.g., *.info, is required"
+	}
+	file.filters = filters
+
+	return file, errs
+}
+
+// setFilters replaces the facility list of an action
+func setFilters(sess *sr.Session, base string, old, filters []syslogFilter) error {
+	entry := func(f syslogFilter) string {
+		return fmt.Sprintf("%s/facility-filter/facility-list[facility='%s'][severity='%s']",
+			base, facilityIdentity(stripModule(f.Facility)), f.Severity)
+	}
+
+	keep := make(map[string]bool)
+	for _, f := range filters {
+		keep[entry(f)] = true
+	}
+	for _, f := range old {
+		if path := entry(f); !keep[path] {
+			if err := sess.DeleteItem(path, sr.EditDefault); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range filters {
+		if err := sess.SetItem(entry(f), nil, sr.EditDefault); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logFormat returns the identity of a log format, or no value
+func logFormat(format string) string {
+	if format == "" {
+		return ""
+	}
+	return "infix-syslog:" + format
+}
+
+// remotePath returns the xpath of a remote server
+func remotePath(name string) string {
+	return fmt.Sprintf("%s/actions/remote/destination[name='%s']", syslogXPath, name)
+}
+
+// logFilePath returns the xpath of a log file
+func logFilePath(name string) string {
+	return fmt.Sprintf("%s/actions/file/log-file[name='%s']", syslogXPath, name)
+}
+
+// findRemote returns the configured remote server, if any
+func (info *LogConfigInfo) findRemote(name string) *SyslogRemote {
+	for i := range info.Remotes {
+		if info.Remotes[i].Name == name {
+			return &info.Remotes[i]
+		}
+	}
+	return nil
+}
+
+// findFile returns the configured log file, if any
+func (info *LogConfigInfo) findFile(name string) *SyslogLogFile {
+	for i := range info.Files {
+		if info.Files[i].Name == name {
+			return &info.Files[i]
+		}
+	}
+	return nil
+}
+
+// errorsFor returns the problems of a form, if it was the one submitted
+func (info *LogConfigInfo) errorsFor(id string) map[string]string {
+	if info.Form != id {
+		return nil
+	}
+	return info.Errors
+}
+
+// RemoteForms returns the forms of the remote servers, and for adding
+// one, last
+func (info *LogConfigInfo) RemoteForms() []LogConfigForm {
+	var forms []LogConfigForm
+	for _, remote := range info.Remotes {
+		id := "remote:" + remote.Name
+		forms = append(forms, LogConfigForm{ID: id, Remote: remote, Errors: info.errorsFor(id)})
+	}
+
+	return append(forms, LogConfigForm{ID: "new-remote", New: true, Remote: info.NewRemote, Errors: info.errorsFor("new-remote")})
+}
+
+// FileForms returns the forms of the log files, and for adding one, last
+func (info *LogConfigInfo) FileForms() []LogConfigForm {
+	var forms []LogConfigForm
+	for _, file := range info.Files {
+		id := "file:" + file.Name
+		forms = append(forms, LogConfigForm{ID: id, File: file, Errors: info.errorsFor(id)})
+	}
+
+	return append(forms, LogConfigForm{ID: "new-file", New: true, File: info.NewFile, Errors: info.errorsFor("new-file")})
+}
+
+// RotationErrors returns the problems of the rotation form
+func (info *LogConfigInfo) RotationErrors() map[string]string {
+	return info.errorsFor("rotation")
+}
+
+// renderLogConfig renders the log settings page, as currently
+// configured, with the result of a change
+func renderLogConfig(w http.ResponseWriter, r *http.Request, message, failure string) {
+	info, err := getSyslogConfig()
+	if err != nil {
+		log.Printf("Error reading syslog configuration: %v", err)
+		info = &LogConfigInfo{Error: "Failed to read the syslog configuration"}
+	}
+
+	info.Message = message
+	if failure != "" {
+		info.Error = failure
+	}
+	renderLogConfigInfo(w, r, info)
+}
+
+// renderLogConfigInfo renders the log settings page
+func renderLogConfigInfo(w http.ResponseWriter, r *http.Request, info *LogConfigInfo) {
+	info.Facilities = facilityNames
+	info.Severities = syslogSeverities
+	if info.NewRemote.Protocol == "" {
+		info.NewRemote.Protocol = "udp"
+	}
+
+	renderPage(w, r, "logconfig", info)
+}
+
+// logConfigHandler shows the syslog configuration
+func logConfigHandler(w http.ResponseWriter, r *http.Request) {
+	renderLogConfig(w, r, "", "")
+}
+
+// saveRemoteHandler adds, or changes, a remote syslog server
+func saveRemoteHandler(w http.ResponseWriter, r *http.Request) {
+	remote, errs := parseRemoteForm(r)
+
+	info, err := getSyslogConfig()
+	if err != nil {
+		log.Printf("Error reading syslog configuration: %v", err)
+		renderLogConfig(w, r, "", "Failed to read the syslog configuration")
+		return
+	}
+
+	form := r.FormValue("form")
+	old := info.findRemote(remote.Name)
+	if form == "new-remote" && old != nil {
+		errs["name"] = "A remote server with this name already exists"
+	}
+
+	if len(errs) > 0 {
+		// Show the form again, as entered, with the problems
+		info.Form, info.Errors = form, errs
+		if form == "new-remote" {
+			info.NewRemote = remote
+		} else if old != nil {
+			*old = remote
+		}
+		renderLogConfigInfo(w, r, info)
+		return
+	}
+
+	var oldFilters []syslogFilter
+	if old != nil {
+		oldFilters = old.filters
+	}
+
+	err = editConfig(func(sess *sr.Session) error {
+		base := remotePath(remote.Name)
+
+		// Only one transport at a time
+		other := "tls"
+		if remote.Protocol == "tls" {
+			other = "udp"
+		}
+		if err := sess.DeleteItem(base+"/"+other, sr.EditDefault); err != nil {
+			return err
+		}
+
+		leaves := map[string]string{
+			remote.Protocol + "/address": remote.Address,
+			remote.Protocol + "/port":    remote.Port,
+			"infix-syslog:log-format":    logFormat(remote.Format),
+		}
+		if err := setItems(sess, base, leaves); err != nil {
+			return err
+		}
+
+		return setFilters(sess, base, oldFilters, remote.filters)
+	})
+	if err != nil {
+		log.Printf("Error saving remote syslog server %s: %v", remote.Name, err)
+		renderLogConfig(w, r, "", fmt.Sprintf("Failed to save remote server %s: %v", remote.Name, err))
+		return
+	}
+
+	log.Printf("Remote syslog server %s applied by %s", remote.Name, getUsername(r))
+	renderLogConfig(w, r, fmt.Sprintf("Remote server %s applied", remote.Name), "")
+}
+
+// deleteRemoteHandler removes a remote syslog server
+func deleteRemoteHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if !syslogName.MatchString(name) {
+		http.Error(w, "Invalid remote server name", http.StatusBadRequest)
+		return
+	}
+
+	err := editConfig(func(sess *sr.Session) error {
+		return sess.DeleteItem(remotePath(name), sr.EditDefault)
+	})
+	if err != nil {
+		log.Printf("Error deleting remote syslog server %s: %v", name, err)
+		renderLogConfig(w, r, "", fmt.Sprintf("Failed to delete remote server %s: %v", name, err))
+		return
+	}
+
+	log.Printf("Remote syslog server %s deleted by %s", name, getUsername(r))
+	renderLogConfig(w, r, fmt.Sprintf("Remote server %s deleted", name), "")
+}
+
+// saveLogFileHandler adds, or changes, a local log file
+func saveLogFileHandler(w http.ResponseWriter, r *http.Request) {
+	file, errs := parseFileForm(r)
+
+	info, err := getSyslogConfig()
+	if err != nil {
+		log.Printf("Error reading syslog configuration: %v", err)
+		renderLogConfig(w, r, "", "Failed to read the syslog configuration")
+		return
+	}
+
+	form := r.FormValue("form")
+	old := info.findFile(file.Name)
+	if form == "new-file" && old != nil {
+		errs["name"] = "A log file with this name already exists"
+	}
+
+	if len(errs) > 0 {
+		info.Form, info.Errors = form, errs
+		if form == "new-file" {
+			info.NewFile = file
+		} else if old != nil {
+			*old = file
+		}
+		renderLogConfigInfo(w, r, info)
+		return
+	}
+
+	var oldFilters []syslogFilter
+	if old != nil {
+		oldFilters = old.filters
+	}
+
+	err = editConfig(func(sess *sr.Session) error {
+		base := logFilePath(file.Name)
+		leaves := map[string]string{
+			"file-rotation/number-of-files": file.NumberOfFiles,
+			"file-rotation/max-file-size":   file.MaxFileSize,
+			"infix-syslog:log-format":       logFormat(file.Format),
+		}
+		if err := setItems(sess, base, leaves); err != nil {
+			return err
+		}
+
+		return setFilters(sess, base, oldFilters, file.filters)
+	})
+	if err != nil {
+		log.Printf("Error saving log file %s: %v", file.Name, err)
+		renderLogConfig(w, r, "", fmt.Sprintf("Failed to save log file %s: %v", file.Name, err))
+		return
+	}
+
+	log.Printf("Log file %s applied by %s", file.Name, getUsername(r))
+	renderLogConfig(w, r, fmt.Sprintf("Log file %s applied", file.Name), "")
+}
+
+// deleteLogFileHandler removes a local log file, the file itself is
+// kept
+func deleteLogFileHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if !syslogFileName.MatchString(name) {
+		http.Error(w, "Invalid log file name", http.StatusBadRequest)
+		return
+	}
+
+	err := editConfig(func(sess *sr.Session) error {
+		return sess.DeleteItem(logFilePath(name), sr.EditDefault)
+	})
+	if err != nil {
+		log.Printf("Error deleting log file %s: %v", name, err)
+		renderLogConfig(w, r, "", fmt.Sprintf("Failed to delete log file %s: %v", name, err))
+		return
+	}
+
+	log.Printf("Log file %s deleted by %s", name, getUsername(r))
+	renderLogConfig(w, r, fmt.Sprintf("Log file %s deleted", name), "")
+}
+
+// saveRotationHandler changes the default rotation of log files
+func saveRotationHandler(w http.ResponseWriter, r *http.Request) {
+	rotation := SyslogRotation{
+		NumberOfFiles: strings.TrimSpace(r.FormValue("number-of-files")),
+		MaxFileSize:   strings.TrimSpace(r.FormValue("max-file-size")),
+	}
+
+	errs := make(map[string]string)
+	validateNumber(errs, "number-of-files", rotation.NumberOfFiles, 1, 100)
+	validateQuantity(errs, "max-file-size", rotation.MaxFileSize, 1, maxLogFileSize, "kB")
+	if len(errs) > 0 {
+		info, err := getSyslogConfig()
+		if err != nil {
+			log.Printf("Error reading syslog configuration: %v", err)
+			renderLogConfig(w, r, "", "Failed to read the syslog configuration")
+			return
+		}
+		info.Form, info.Errors, info.Rotation = "rotation", errs, rotation
+		renderLogConfigInfo(w, r, info)
+		return
+	}
+
+	err := editConfig(func(sess *sr.Session) error {
+		return setItems(sess, syslogXPath+"/infix-syslog:file-rotation", map[string]string{
+			"number-of-files": rotation.NumberOfFiles,
+			"max-file-size":   rotation.MaxFileSize,
+		})
+	})
+	if err != nil {
+		log.Printf("Error saving log rotation: %v", err)
+		renderLogConfig(w, r, "", fmt.Sprintf("Failed to save log rotation: %v", err))
+		return
+	}
+
+	log.Printf("Log rotation applied by %s", getUsername(r))
+	renderLogConfig(w, r, "Log rotation applied", "")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQuantity(t *testing.T) {
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{"", ""},
+		{"1", ""},
+		{"1024", ""},
+		{"1048576", ""},
+		{"4294967295", ""},
+		{"0", "Must be a number of kB from 1 to 4294967295"},
+		{"4294967296", "Must be a number of kB from 1 to 4294967295"},
+		{"-1", "Must be a number of kB from 1 to 4294967295"},
+		{"1k", "Must be a number of kB from 1 to 4294967295"},
+	}
+
+	for _, tt := range tests {
+		errs := make(map[string]string)
+		validateQuantity(errs, "max-file-size", tt.value, 1, maxLogFileSize, "kB")
+		if errs["max-file-size"] != tt.err {
+			t.Errorf("validateQuantity(%q) = %q, want %q", tt.value, errs["max-file-size"], tt.err)
+		}
+	}
+
+	errs := make(map[string]string)
+	validateNumber(errs, "port", "65536", 1, 65535)
+	if errs["port"] != "Must be a number from 1 to 65535" {
+		t.Errorf("validateNumber(65536) = %q", errs["port"])
+	}
+}
+
+func TestFacilityIdentity(t *testing.T) {
+	tests := map[string]string{
+		"kern":                   "ietf-syslog:kern",
+		"console":                "ietf-syslog:console",
+		"cron2":                  "ietf-syslog:cron2",
+		"local7":                 "ietf-syslog:local7",
+		"all":                    "all",
+		"rauc":                   "infix-syslog:rauc",
+		"infix-syslog:container": "infix-syslog:container",
+	}
+	for name, want := range tests {
+		if got := facilityIdentity(name); got != want {
+			t.Errorf("facilityIdentity(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// Facilities in the log viewer are named as in the log settings
+	for _, pri := range []string{"<112>", "<120>"} {
+		facility, _, _ := parsePriority(pri + "Mar  5 10:11:12 host app: message")
+		entry := SyslogEntry{Facility: facility}
+		if id := facilityIdentity(entry.FacilityName()); !strings.HasPrefix(id, "ietf-syslog:") {
+			t.Errorf("facility %d named %q, not of ietf-syslog", facility, entry.FacilityName())
+		}
+	}
+}
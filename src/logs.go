@@ -1,28 +1,74 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/binary"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type LogInfo struct {
-	Files     []string
-	ActiveLog string
-	Content   string
+	Groups       []LogGroup
+	ActiveLog    string
+	Group        bool
+	Source       template.URL
+	ViewLink     template.URL
+	Raw          bool
+	Content      string
+	Lines        []LogLine
+	Page         *LogPage
+	Follow       bool
+	FollowFile   string
+	FollowOffset int64
+	Filter       *LogFilter
+	NoPriority   bool
+	Result       *LogResult
+	Error        string
 }
 
-// logHandler handles the log viewing page
+// LogGroup is a log file and its rotations, newest first
+type LogGroup struct {
+	Name  string
+	Files []string
+}
+
+// LogLine is a line of a log, parsed for the log table if it is in
+// syslog format
+type LogLine struct {
+	Line     int
+	Entry    *SyslogEntry
+	Segments []LogSegment
+}
+
+// Rotated log files, e.g., messages.0 and messages.1.gz
+var rotatedLogFile = regexp.MustCompile(`^(.+)\.(\d+)(\.gz)?$`)
+
+var (
+	// Directory of the log files
+	logDir = "/var/log"
+	// Compressed log files decompressed for reading pages, kept until
+	// they are rotated away
+	logCacheDir = "/tmp/webui-logs"
+)
+
+// logHandler handles the log viewing page, of a single log file, or
+// of a log and its rotations as one
 func logHandler(w http.ResponseWriter, r *http.Request) {
 	// Get list of log files
-	files, err := listLogFiles()
+	groups, err := listLogGroups()
 	if err != nil {
 		log.Printf("Error listing log files: %v", err)
 		http.Error(w, "Failed to read log directory", http.StatusInternalServerError)
@@ -31,37 +77,155 @@ func logHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Default display - no file selected yet
 	info := &LogInfo{
-		Files:     files,
+		Groups:    groups,
 		ActiveLog: "",
 		Content:   "",
 	}
 
+	q := r.URL.Query()
+	name, param := q.Get("file"), "file"
+	if group := q.Get("group"); group != "" {
+		name, param = group, "group"
+	}
+
 	// Check if a specific log file is requested
-	if logFile := r.URL.Query().Get("file"); logFile != "" {
+	if name != "" {
 		// Validate the filename to prevent directory traversal
-		if strings.Contains(logFile, "..") || strings.Contains(logFile, "/") {
+		if strings.Contains(name, "..") || strings.Contains(name, "/") {
 			http.Error(w, "Invalid log filename", http.StatusBadRequest)
 			return
 		}
 
-		content, err := readLogFile(logFile)
+		var f logFile
+		if param == "group" {
+			group := findLogGroup(groups, name)
+			if group == nil {
+				http.Error(w, "Log file not found", http.StatusNotFound)
+				return
+			}
+			f, err = openLogGroup(group)
+
+			// Only the current file of the log can be followed
+			if group.Files[0] == group.Name {
+				info.FollowFile = group.Name
+			}
+		} else {
+			f, err = openLogFile(name)
+			info.FollowFile = name
+		}
 		if err != nil {
-			log.Printf("Error reading log file %s: %v", logFile, err)
+			log.Printf("Error opening log file %s: %v", name, err)
 			http.Error(w, fmt.Sprintf("Failed to read log file: %v", err), http.StatusInternalServerError)
 			return
 		}
+		defer f.Close()
+
+		if strings.HasSuffix(info.FollowFile, ".gz") {
+			info.FollowFile = ""
+		}
 
-		info.ActiveLog = logFile
-		info.Content = content
+		info.ActiveLog = name
+		info.Group = param == "group"
+		info.Raw = q.Get("view") == "raw"
+		info.Source = template.URL(param + "=" + url.QueryEscape(name))
+		if info.Raw {
+			info.Source += "&view=raw"
+		}
+
+		// Switch between the table and the raw text, on the same page
+		toggle := url.Values{}
+		for key, values := range q {
+			toggle[key] = values
+		}
+		if info.Raw {
+			toggle.Del("view")
+		} else {
+			toggle.Set("view", "raw")
+		}
+		info.ViewLink = template.URL(toggle.Encode())
+
+		filter, err := parseLogFilter(q)
+		if err != nil {
+			info.Error = fmt.Sprintf("Invalid filter: %v", err)
+		}
+		info.Filter = filter
+		info.NoPriority = !hasPriorities(f)
+
+		var end int64
+		if filter.Active() {
+			page, _ := strconv.Atoi(q.Get("page"))
+			info.Result, err = searchLogFile(f, filter, page)
+			if err != nil {
+				log.Printf("Error searching log file %s: %v", name, err)
+				http.Error(w, fmt.Sprintf("Failed to search log file: %v", err), http.StatusInternalServerError)
+				return
+			}
+			end = f.Size()
+		} else {
+			info.Page, err = readLogQuery(f, q)
+			if err != nil {
+				log.Printf("Error reading log file %s: %v", name, err)
+				http.Error(w, fmt.Sprintf("Failed to read log file: %v", err), http.StatusInternalServerError)
+				return
+			}
+			info.Content = info.Page.Content
+			end = info.Page.End
+		}
+
+		if !info.Raw {
+			info.Lines = parseLogLines(info, filter)
+		}
+
+		// Only the newest lines can be followed, from where the page
+		// ends in the current file
+		if info.FollowFile != "" && end == f.Size() {
+			info.Follow = true
+			info.FollowOffset = end
+			if m, ok := f.(*mergedLogFile); ok {
+				info.FollowOffset -= m.starts[len(m.starts)-1]
+			}
+		}
 	}
 
 	renderPage(w, r, "log", info)
 }
 
+// parseLogLines parses the lines of the page, or of the search result,
+// for the log table.  Matches are highlighted in the message only.
+func parseLogLines(info *LogInfo, filter *LogFilter) []LogLine {
+	var lines []LogLine
+	now := time.Now()
+
+	add := func(lineno int, text string) {
+		line := LogLine{Line: lineno}
+		if entry, ok := parseSyslogLine(text, now); ok {
+			line.Entry = entry
+			text = entry.Message
+		}
+		line.Segments = filter.Highlight(text)
+		lines = append(lines, line)
+	}
+
+	if info.Result != nil {
+		for _, match := range info.Result.Matches {
+			add(match.Line, match.Text)
+		}
+		return lines
+	}
+
+	for _, text := range strings.Split(strings.TrimSuffix(info.Content, "\n"), "\n") {
+		if text != "" {
+			add(0, strings.TrimSuffix(text, "\r"))
+		}
+	}
+
+	return lines
+}
+
 // listLogFiles returns a sorted list of log files from /var/log
 func listLogFiles() ([]string, error) {
 	// Read the log directory
-	files, err := os.ReadDir("/var/log")
+	files, err := os.ReadDir(logDir)
 	if err != nil {
 		return nil, err
 	}
@@ -78,103 +242,549 @@ func listLogFiles() ([]string, error) {
 	return logs, nil
 }
 
-// readLogFile reads the content of a log file, handling gzipped files
-func readLogFile(filename string) (string, error) {
-	// Construct the full file path
-	filePath := filepath.Join("/var/log", filename)
+// listLogGroups returns the log files in /var/log grouped with their
+// rotations, e.g., messages, messages.0, messages.1.gz
+func listLogGroups() ([]LogGroup, error) {
+	files, err := listLogFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := make(map[string]int)
+	byName := make(map[string]*LogGroup)
+	var names []string
 
-	// Check if the file exists
-	_, err := os.Stat(filePath)
-	if os.IsNotExist(err) {
-		return "", fmt.Errorf("log file not found")
+	for _, file := range files {
+		name := file
+		rotation[file] = -1
+		if m := rotatedLogFile.FindStringSubmatch(file); m != nil {
+			name = m[1]
+			rotation[file], _ = strconv.Atoi(m[2])
+		}
+
+		group, ok := byName[name]
+		if !ok {
+			group = &LogGroup{Name: name}
+			byName[name] = group
+			names = append(names, name)
+		}
+		group.Files = append(group.Files, file)
+	}
+
+	sort.Strings(names)
+	groups := make([]LogGroup, 0, len(names))
+	for _, name := range names {
+		group := byName[name]
+
+		// Newest first, the current file has no rotation number
+		sort.Slice(group.Files, func(i, j int) bool {
+			return rotation[group.Files[i]] < rotation[group.Files[j]]
+		})
+		groups = append(groups, *group)
+	}
+
+	return groups, nil
+}
+
+// findLogGroup returns the named group, if it exists
+func findLogGroup(groups []LogGroup, name string) *LogGroup {
+	for i := range groups {
+		if groups[i].Name == name {
+			return &groups[i]
+		}
+	}
+
+	return nil
+}
+
+// Rotated returns true if the log has been rotated
+func (g LogGroup) Rotated() bool {
+	return len(g.Files) > 1
+}
+
+// Rotations returns the rotated files of the log, newest first
+func (g LogGroup) Rotations() []string {
+	if g.Files[0] == g.Name {
+		return g.Files[1:]
+	}
+	return g.Files
+}
+
+// logFile is a log file that can be read at any offset, or from start
+// to end as a stream, e.g., to search it
+type logFile interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	Stream() (io.ReadCloser, error)
+}
+
+// plainLogFile is an uncompressed log file on disk
+type plainLogFile struct {
+	*os.File
+	size int64
+}
+
+func (f *plainLogFile) Size() int64 {
+	return f.size
+}
+
+// Stream reads the file, up to its size when opened
+func (f *plainLogFile) Stream() (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(f.File, 0, f.size)), nil
+}
+
+// gzipLogFile is a compressed log file.  It is decompressed as it is
+// streamed, and, for reading pages, to a file in logCacheDir, once.
+type gzipLogFile struct {
+	path  string
+	key   string
+	size  int64
+	cache *os.File
+}
+
+// openGzipLogFile opens a compressed log file, its size is taken from
+// the gzip trailer, as written by logrotate and gzip, in one member
+func openGzipLogFile(path string) (*gzipLogFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gzip.NewReader(file); err != nil {
+		return nil, err
+	}
+
+	var trailer [4]byte
+	if _, err := file.ReadAt(trailer[:], fi.Size()-4); err != nil {
+		return nil, fmt.Errorf("truncated gzip file")
+	}
+
+	return &gzipLogFile{
+		path: path,
+		key:  logCacheKey(fi),
+		size: int64(binary.LittleEndian.Uint32(trailer[:])),
+	}, nil
+}
+
+func (f *gzipLogFile) Size() int64 {
+	return f.size
+}
+
+func (f *gzipLogFile) Close() error {
+	if f.cache != nil {
+		return f.cache.Close()
+	}
+	return nil
+}
+
+// ReadAt reads the decompressed file, decompressed on the first read
+func (f *gzipLogFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.cache == nil {
+		cache, err := openLogCache(f)
+		if err != nil {
+			return 0, err
+		}
+		f.cache = cache
+	}
+
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	if left := f.size - off; int64(len(p)) > left {
+		n, err := f.cache.ReadAt(p[:left], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return f.cache.ReadAt(p, off)
+}
+
+// Stream decompresses the file as it is read
+func (f *gzipLogFile) Stream() (io.ReadCloser, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &gzipStream{Reader: io.LimitReader(gz, f.size), file: file}, nil
+}
+
+// gzipStream is a decompressing reader of a file
+type gzipStream struct {
+	io.Reader
+	file *os.File
+}
+
+func (s *gzipStream) Close() error {
+	return s.file.Close()
+}
+
+// logCacheKey returns the name of the decompressed copy of a compressed
+// log file, which changes if the file is replaced
+func logCacheKey(fi os.FileInfo) string {
+	var ino uint64
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		ino = st.Ino
+	}
+	return fmt.Sprintf("%d-%d-%d", ino, fi.ModTime().UnixNano(), fi.Size())
+}
+
+// openLogCache opens the decompressed copy of a compressed log file,
+// decompressing it if there is none.  Copies of rotated away files are
+// removed.
+func openLogCache(f *gzipLogFile) (*os.File, error) {
+	name := filepath.Join(logCacheDir, f.key)
+	if cache, err := os.Open(name); err == nil {
+		return cache, nil
+	}
+
+	if err := os.MkdirAll(logCacheDir, 0700); err != nil {
+		return nil, err
+	}
+	pruneLogCache()
+
+	src, err := f.Stream()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(logCacheDir, ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return nil, err
+	}
+
+	return os.Open(name)
+}
+
+// pruneLogCache removes the decompressed copies of compressed log files
+// no longer in logDir, e.g., rotated away
+func pruneLogCache() {
+	keep := make(map[string]bool)
+	files, _ := listLogFiles()
+	for _, file := range files {
+		if fi, err := os.Stat(filepath.Join(logDir, file)); err == nil && strings.HasSuffix(file, ".gz") {
+			keep[logCacheKey(fi)] = true
+		}
+	}
+
+	entries, _ := os.ReadDir(logCacheDir)
+	for _, entry := range entries {
+		if !keep[entry.Name()] && !strings.HasPrefix(entry.Name(), ".tmp-") {
+			os.Remove(filepath.Join(logCacheDir, entry.Name()))
+		}
 	}
+}
+
+// mergedLogFile is a log and its rotations read as one file, oldest
+// rotation first
+type mergedLogFile struct {
+	names  []string
+	parts  []logFile
+	starts []int64
+	sizes  []int64
+	size   int64
+}
+
+// openLogGroup opens the files of a log group as one.  Only their sizes
+// are read, the files are opened when read.
+func openLogGroup(group *LogGroup) (logFile, error) {
+	m := &mergedLogFile{}
+	for i := len(group.Files) - 1; i >= 0; i-- {
+		size, err := logFileSize(group.Files[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", group.Files[i], err)
+		}
 
-	// Check if it's a large file (>1MB) and use tail instead
-	fileInfo, err := os.Stat(filePath)
-	if err == nil && fileInfo.Size() > 1024*1024 {
-		return tailLogFile(filename, "1000")
+		m.names = append(m.names, group.Files[i])
+		m.starts = append(m.starts, m.size)
+		m.sizes = append(m.sizes, size)
+		m.size += size
 	}
+	m.parts = make([]logFile, len(m.names))
 
-	// Check if the file is gzipped
+	return m, nil
+}
+
+// logFileSize returns the size of a log file in logDir, decompressed,
+// without reading it
+func logFileSize(filename string) (int64, error) {
+	path := filepath.Join(logDir, filename)
 	if strings.HasSuffix(filename, ".gz") {
-		// Open the gzipped file
-		file, err := os.Open(filePath)
+		f, err := openGzipLogFile(path)
 		if err != nil {
-			return "", err
+			return 0, err
 		}
-		defer file.Close()
+		return f.Size(), nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
 
-		// Create a gzip reader
-		gzipReader, err := gzip.NewReader(file)
+// part returns a file of the log, opened on first use
+func (m *mergedLogFile) part(i int) (logFile, error) {
+	if m.parts[i] == nil {
+		f, err := openLogFile(m.names[i])
 		if err != nil {
-			return "", err
+			return nil, fmt.Errorf("%s: %w", m.names[i], err)
+		}
+		m.parts[i] = f
+	}
+	return m.parts[i], nil
+}
+
+func (m *mergedLogFile) Size() int64 {
+	return m.size
+}
+
+func (m *mergedLogFile) Close() error {
+	for _, part := range m.parts {
+		if part != nil {
+			part.Close()
+		}
+	}
+	return nil
+}
+
+// Stream reads the files of the log, one after the other
+func (m *mergedLogFile) Stream() (io.ReadCloser, error) {
+	return &mergedStream{m: m}, nil
+}
+
+// mergedStream reads the files of a log, streaming one at a time
+type mergedStream struct {
+	m    *mergedLogFile
+	next int
+	part io.ReadCloser
+}
+
+func (s *mergedStream) Read(p []byte) (int, error) {
+	for {
+		if s.part == nil {
+			if s.next == len(s.m.parts) {
+				return 0, io.EOF
+			}
+			f, err := s.m.part(s.next)
+			if err != nil {
+				return 0, err
+			}
+			part, err := f.Stream()
+			if err != nil {
+				return 0, err
+			}
+			s.part = &limitedStream{io.LimitReader(part, s.m.sizes[s.next]), part}
+			s.next++
+		}
+
+		n, err := s.part.Read(p)
+		if err == io.EOF {
+			s.part.Close()
+			s.part = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *mergedStream) Close() error {
+	if s.part != nil {
+		return s.part.Close()
+	}
+	return nil
+}
+
+// limitedStream is a stream of a file of a log, up to its size when
+// the log was opened
+type limitedStream struct {
+	io.Reader
+	io.Closer
+}
+
+// ReadAt reads across the files of the log, as of when it was opened,
+// opening only the files of the part read
+func (m *mergedLogFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for i := range m.names {
+		end := m.starts[i] + m.sizes[i]
+		if len(p) == 0 {
+			break
+		}
+		if off >= end {
+			continue
 		}
-		defer gzipReader.Close()
 
-		// Read the content
-		content, err := io.ReadAll(gzipReader)
+		part, err := m.part(i)
 		if err != nil {
-			return "", err
+			return n, err
 		}
 
-		return string(content), nil
+		want := int(min(int64(len(p)), end-off))
+		k, err := part.ReadAt(p[:want], off-m.starts[i])
+		n += k
+		off += int64(k)
+		p = p[k:]
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if k < want {
+			// Truncated since it was opened
+			return n, io.EOF
+		}
 	}
 
-	// For non-gzipped files, read directly
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", err
+	if len(p) > 0 {
+		return n, io.EOF
 	}
 
-	return string(content), nil
+	return n, nil
+}
+
+// Size of a page of a log file
+const logPageBytes = 256 * 1024
+
+// LogPage is a part of a log file, on whole lines
+type LogPage struct {
+	Start   int64
+	End     int64
+	Size    int64
+	Content string
+}
+
+// Older returns true if there is more of the log before the page
+func (p *LogPage) Older() bool {
+	return p.Start > 0
+}
+
+// Newer returns true if there is more of the log after the page
+func (p *LogPage) Newer() bool {
+	return p.End < p.Size
 }
 
-// tailLogFile executes the tail command on a log file
-func tailLogFile(filename string, lines string) (string, error) {
-	if lines == "" {
-		lines = "100" // Default to 100 lines
+// openLogFile opens a log file in logDir, gzipped files are
+// decompressed transparently
+func openLogFile(filename string) (logFile, error) {
+	path := filepath.Join(logDir, filename)
+	if strings.HasSuffix(filename, ".gz") {
+		f, err := openGzipLogFile(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("log file not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
 	}
 
-	// Construct the full file path
-	filePath := filepath.Join("/var/log", filename)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("log file not found")
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// Execute the tail command
-	cmd := exec.Command("tail", "-n", lines, filePath)
-	output, err := cmd.Output()
+	fi, err := file.Stat()
 	if err != nil {
-		return "", err
+		file.Close()
+		return nil, err
 	}
 
-	return string(output), nil
+	return &plainLogFile{File: file, size: fi.Size()}, nil
 }
 
-// tailLogHandler handles AJAX requests for tailing logs
-func tailLogHandler(w http.ResponseWriter, r *http.Request) {
-	// Get log filename from query parameter
-	filename := r.URL.Query().Get("file")
-	if filename == "" {
-		http.Error(w, "Log filename is required", http.StatusBadRequest)
-		return
+// readLogPage reads a page of a log file.  With before set, the page
+// ends at that offset, with after set it starts there, otherwise it is
+// the last page of the log.  Pages start and end on whole lines, unless
+// a line is longer than a page.
+func readLogPage(f logFile, before, after int64) (*LogPage, error) {
+	size := f.Size()
+	page := &LogPage{Size: size}
+
+	switch {
+	case after >= 0:
+		page.Start = min(after, size)
+		page.End = min(page.Start+logPageBytes, size)
+	case before >= 0:
+		page.End = min(before, size)
+		page.Start = max(page.End-logPageBytes, 0)
+	default:
+		page.End = size
+		page.Start = max(size-logPageBytes, 0)
 	}
 
-	// Validate the filename to prevent directory traversal
-	if strings.Contains(filename, "..") || strings.Contains(filename, "/") {
-		http.Error(w, "Invalid log filename", http.StatusBadRequest)
-		return
+	buf := make([]byte, page.End-page.Start)
+	if _, err := f.ReadAt(buf, page.Start); err != nil && err != io.EOF {
+		return nil, err
 	}
 
-	// Get the number of lines to tail
-	lines := r.URL.Query().Get("lines")
+	// Drop the partial first line, unless this is the start of the log
+	if page.Start > 0 && after < 0 {
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 && i < len(buf)-1 {
+			buf = buf[i+1:]
+			page.Start += int64(i + 1)
+		}
+	}
 
-	// Tail the log file
-	content, err := tailLogFile(filename, lines)
-	if err != nil {
-		log.Printf("Error tailing log file %s: %v", filename, err)
-		http.Error(w, fmt.Sprintf("Failed to tail log file: %v", err), http.StatusInternalServerError)
-		return
+	// Drop the partial last line, unless this is the end of the log
+	if page.End < size {
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			page.End -= int64(len(buf) - i - 1)
+			buf = buf[:i+1]
+		}
+	}
+
+	page.Content = string(buf)
+	return page, nil
+}
+
+// readLogQuery reads the page of a log file given by the "before" or
+// "after" offset in the query parameters
+func readLogQuery(f logFile, q url.Values) (*LogPage, error) {
+	var err error
+
+	before, after := int64(-1), int64(-1)
+	if value := q.Get("before"); value != "" {
+		if before, err = strconv.ParseInt(value, 10, 64); err != nil || before < 0 {
+			return nil, fmt.Errorf("invalid offset")
+		}
+	}
+	if value := q.Get("after"); value != "" {
+		if after, err = strconv.ParseInt(value, 10, 64); err != nil || after < 0 {
+			return nil, fmt.Errorf("invalid offset")
+		}
 	}
 
-	// Set content type and write the output
-	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte(content))
+	return readLogPage(f, before, after)
 }
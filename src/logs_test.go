@@ -0,0 +1,236 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logLines returns n lines of a log, numbered from first
+func logLines(first, n int) string {
+	var b strings.Builder
+	for i := first; i < first+n; i++ {
+		b.WriteString("Jan  1 00:00:00 host app: line " + strings.Repeat("x", i%50) + "\n")
+	}
+	return b.String()
+}
+
+// writeLogFiles writes log files to a new logDir and logCacheDir,
+// compressed if their name ends with .gz
+func writeLogFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	savedDir, savedCache := logDir, logCacheDir
+	logDir, logCacheDir = t.TempDir(), filepath.Join(t.TempDir(), "cache")
+	t.Cleanup(func() { logDir, logCacheDir = savedDir, savedCache })
+
+	for name, content := range files {
+		path := filepath.Join(logDir, name)
+		if strings.HasSuffix(name, ".gz") {
+			writeGzip(t, path, content)
+		} else if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// cachedLogs returns the decompressed copies in logCacheDir
+func cachedLogs(t *testing.T) []string {
+	entries, _ := os.ReadDir(logCacheDir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestGzipLogFile(t *testing.T) {
+	content := logLines(0, 20000)
+	writeLogFiles(t, map[string]string{"messages.1.gz": content})
+
+	f, err := openLogFile("messages.1.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Size() != int64(len(content)) {
+		t.Errorf("size = %d, want %d", f.Size(), len(content))
+	}
+
+	// Streamed without a decompressed copy
+	stream, err := f.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(stream)
+	stream.Close()
+	if err != nil || string(got) != content {
+		t.Errorf("stream = %d bytes, %v, want %d bytes", len(got), err, len(content))
+	}
+	if cached := cachedLogs(t); len(cached) != 0 {
+		t.Errorf("cached after streaming: %v", cached)
+	}
+
+	// Pages are read from a copy, decompressed once
+	page, err := readLogPage(f, -1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := content[100 : 100+logPageBytes]; !strings.HasPrefix(want, page.Content) || page.End != 100+int64(len(page.Content)) {
+		t.Errorf("page from 100 = %d..%d, not in the log", page.Start, page.End)
+	}
+	page, err = readLogPage(f, -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(content, page.Content) || page.End != f.Size() {
+		t.Errorf("last page = %d..%d, not the end of the log", page.Start, page.End)
+	}
+	if cached := cachedLogs(t); len(cached) != 1 {
+		t.Errorf("cached = %v, want one copy", cached)
+	}
+}
+
+func TestLogCachePruned(t *testing.T) {
+	writeLogFiles(t, map[string]string{
+		"messages.1.gz": logLines(0, 100),
+		"messages.2.gz": logLines(100, 100),
+	})
+
+	read := func(name string) {
+		f, err := openLogFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := readLogPage(f, -1, -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read("messages.1.gz")
+	read("messages.2.gz")
+	if cached := cachedLogs(t); len(cached) != 2 {
+		t.Fatalf("cached = %v, want two copies", cached)
+	}
+
+	// Rotated: messages.2.gz is removed, messages.1.gz renamed
+	os.Remove(filepath.Join(logDir, "messages.2.gz"))
+	os.Rename(filepath.Join(logDir, "messages.1.gz"), filepath.Join(logDir, "messages.2.gz"))
+	writeGzip(t, filepath.Join(logDir, "messages.1.gz"), logLines(200, 100))
+
+	read("messages.1.gz")
+	cached := cachedLogs(t)
+	if len(cached) != 2 {
+		t.Errorf("cached = %v, want the renamed and the new copy", cached)
+	}
+}
+
+// writeGzip writes a compressed file
+func writeGzip(t *testing.T, path, content string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	io.WriteString(gz, content)
+	gz.Close()
+}
+
+func TestMergedLogStream(t *testing.T) {
+	writeLogFiles(t, map[string]string{
+		"messages":      logLines(300, 100),
+		"messages.0":    logLines(200, 100),
+		"messages.1.gz": logLines(100, 100),
+		"messages.2.gz": logLines(0, 100),
+	})
+
+	groups, err := listLogGroups()
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("groups = %+v, %v", groups, err)
+	}
+	f, err := openLogGroup(&groups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := logLines(0, 400)
+	if f.Size() != int64(len(want)) {
+		t.Errorf("size = %d, want %d", f.Size(), len(want))
+	}
+
+	stream, err := f.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(stream)
+	stream.Close()
+	if err != nil || string(got) != want {
+		t.Errorf("stream = %d bytes, %v, want %d bytes in order", len(got), err, len(want))
+	}
+
+	buf := make([]byte, 200)
+	off := int64(len(logLines(0, 199)))
+	if _, err := f.ReadAt(buf, off); err != nil || string(buf) != want[off:off+200] {
+		t.Errorf("ReadAt(%d) = %q, %v, want across rotations", off, buf, err)
+	}
+}
+
+func TestMergedLogOpensParts(t *testing.T) {
+	writeLogFiles(t, map[string]string{
+		"messages":      logLines(20000, 100),
+		"messages.0":    logLines(10000, 10000),
+		"messages.1.gz": logLines(0, 10000),
+	})
+
+	groups, err := listLogGroups()
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("groups = %+v, %v", groups, err)
+	}
+	f, err := openLogGroup(&groups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m := f.(*mergedLogFile)
+
+	opened := func() []string {
+		var names []string
+		for i, part := range m.parts {
+			if part != nil {
+				names = append(names, m.names[i])
+			}
+		}
+		return names
+	}
+
+	// The last page is of the newest two files only
+	page, err := readLogPage(f, -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(logLines(0, 20100), page.Content) || page.End != f.Size() {
+		t.Errorf("last page = %d..%d, not the end of the log", page.Start, page.End)
+	}
+	if got := opened(); strings.Join(got, " ") != "messages.0 messages" {
+		t.Errorf("opened %v, want messages.0 and messages", got)
+	}
+	if cached := cachedLogs(t); len(cached) != 0 {
+		t.Errorf("decompressed %v for the last page", cached)
+	}
+
+	// The first page is of the oldest
+	if _, err := readLogPage(f, -1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := opened(); len(got) != 3 {
+		t.Errorf("opened %v, want all", got)
+	}
+}
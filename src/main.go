@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strings"
@@ -36,6 +37,7 @@ type PageData struct {
 	Username    string
 	Content     interface{}
 	ManualFiles []string
+	Operation   *Operation
 }
 
 // Command line options
@@ -44,15 +46,30 @@ var (
 	debug      bool
 	secretPath string
 	staticPath string
+	statePath  string
+	// Reverse proxies trusted to give the address of the client, in
+	// X-Forwarded-For or X-Real-IP
+	trustedProxies []string
 )
 
+// The server only listens on localhost, behind nginx, so by default
+// the proxy is trusted on loopback
+var defaultTrustedProxies = []string{"127.0.0.1", "::1"}
+
 func main() {
 	pflag.IntVarP(&port, "port", "p", 8080, "HTTP listening port, default: 8080")
 	pflag.BoolVarP(&debug, "debug", "d", false, "Enable debug mode (allows admin/admin login)")
 	pflag.StringVarP(&sessionPath, "secret", "s", "/var/lib/misc", "Directory for session secret")
 	pflag.StringVarP(&staticPath, "assets", "a", "/usr/share/webui", "Directory for static files")
+	pflag.StringVarP(&statePath, "state", "t", "/var/lib/webui", "Directory for persistent state")
+	pflag.StringSliceVar(&trustedProxies, "trusted-proxy", defaultTrustedProxies, "Address, or prefix, of a reverse proxy trusted with the client address")
 	pflag.Parse()
 
+	realIP, err := trustedRealIP(trustedProxies)
+	if err != nil {
+		log.Fatal("Invalid trusted proxy:", err)
+	}
+
 	if err := verifyDirs(); err != nil {
 		log.Println("Check the --assets path argument.")
 		os.Exit(1)
@@ -70,7 +87,16 @@ func main() {
 		log.Fatal("Failed to load templates:", err)
 	}
 
+	if err := os.MkdirAll(statePath, 0700); err != nil {
+		log.Fatal("Failed to create state directory:", err)
+	}
+
+	checkFirstBoot()
+	startScheduler()
+	startStatsSampler()
+
 	r := chi.NewRouter()
+	r.Use(realIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
@@ -97,13 +123,52 @@ func main() {
 		r.Get("/manual", manualHandler)
 		r.Get("/manual/{name}", manualHandler)
 		r.Get("/network", networkHandler)
+		r.Get("/network/stats", statsHandler)
+		r.Post("/network/clear-counters", clearCountersHandler)
+		r.Get("/network/neighbors", neighborsHandler)
+		r.Post("/network/neighbors/flush", flushNeighborHandler)
+		r.Get("/network/{ifname}", interfaceHandler)
+		r.With(configChanged).Post("/network/{ifname}", saveInterfaceHandler)
+		r.Get("/bridge", bridgeHandler)
+		r.With(configChanged).Post("/bridge/{name}/vlans", saveBridgeVLANsHandler)
+		r.With(configChanged).Post("/bridge/{name}/vlans/delete", deleteBridgeVLANHandler)
+		r.Get("/routes", routesHandler)
+		r.Get("/routes/rib", ribHandler)
+		r.With(configChanged).Post("/routes", saveRouteHandler)
+		r.With(configChanged).Post("/routes/delete", deleteRouteHandler)
 		r.Get("/log", logHandler)
-		r.Get("/tail-log", tailLogHandler)
+		r.Get("/follow-log", followLogHandler)
+		r.Get("/download-logs", downloadLogsHandler)
+		r.Get("/dmesg", dmesgHandler)
+		r.Get("/log-settings", logConfigHandler)
+		r.With(configChanged).Post("/log-settings/remote", saveRemoteHandler)
+		r.With(configChanged).Post("/log-settings/remote/delete", deleteRemoteHandler)
+		r.With(configChanged).Post("/log-settings/file", saveLogFileHandler)
+		r.With(configChanged).Post("/log-settings/file/delete", deleteLogFileHandler)
+		r.With(configChanged).Post("/log-settings/rotation", saveRotationHandler)
+		r.Get("/follow-dmesg", followDmesgHandler)
+		r.Get("/support-bundle", supportBundleHandler)
 		r.Get("/upgrade", upgradeHandler)
 		r.Get("/download-config", downloadConfigHandler)
+		r.Post("/inspect-firmware", inspectFirmwareHandler)
 		r.Post("/upload-firmware", uploadFirmwareHandler)
+		r.Post("/uploads", createUploadHandler)
+		r.Head("/uploads/{id}", uploadOffsetHandler)
+		r.Get("/uploads/{id}", uploadOffsetHandler)
+		r.Patch("/uploads/{id}", uploadChunkHandler)
+		r.Delete("/uploads/{id}", deleteUploadHandler)
 		r.Get("/upgrade-status", upgradeStatusHandler)
 		r.Post("/reboot", rebootHandler)
+		r.Get("/schedule", scheduleHandler)
+		r.Post("/schedule/upgrade", scheduleUpgradeHandler)
+		r.Post("/schedule/reboot", scheduleRebootHandler)
+		r.Post("/schedule/{id}/cancel", cancelJobHandler)
+		r.Get("/slots", slotsHandler)
+		r.Post("/slots/mark", markSlotHandler)
+		r.Get("/config/unsaved", configBannerHandler)
+		r.Post("/config/save", saveConfigHandler)
+		r.Get("/operation", operationHandler)
+		r.Post("/operation/cancel", cancelOperationHandler)
 	})
 
 	// Only localhost, use nginx or similar to access
@@ -112,6 +177,40 @@ func main() {
 	log.Fatal(http.ListenAndServe(listenAddr, r))
 }
 
+// trustedRealIP returns a middleware setting the remote address of
+// requests from the trusted proxies to the client address they give.
+// Other clients could claim any address, so theirs is kept.
+func trustedRealIP(proxies []string) (func(http.Handler) http.Handler, error) {
+	var prefixes []netip.Prefix
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an address or prefix", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return func(next http.Handler) http.Handler {
+		proxied := middleware.RealIP(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+				for _, prefix := range prefixes {
+					if prefix.Contains(addr.Addr().Unmap()) {
+						proxied.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
 func verifyDirs() error {
 	requiredDirs := []string{"assets", "templates"}
 
@@ -210,6 +309,7 @@ func renderPage(w http.ResponseWriter, r *http.Request, nm string, info interfac
 		Username:    getUsername(r),
 		Content:     info,
 		ManualFiles: manualFiles,
+		Operation:   getOperation(),
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
@@ -225,6 +325,26 @@ func renderPage(w http.ResponseWriter, r *http.Request, nm string, info interfac
 	}
 }
 
+// renderFragment renders a template defined in the layout, e.g., for
+// parts of the page that are refreshed on their own
+func renderFragment(w http.ResponseWriter, name string, data interface{}) {
+	for _, tmpl := range templates {
+		if tmpl.Lookup(name) == nil {
+			continue
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+			log.Printf("Template error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Template %s not found", name)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
 func loginPageHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if already logged in
 	if _, err := r.Cookie("session"); err == nil {
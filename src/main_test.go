@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedRealIP(t *testing.T) {
+	realIP, err := trustedRealIP([]string{"127.0.0.1", "fd00::/64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	handler := realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = remoteAddr(r)
+	}))
+
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		want   string
+	}{
+		{"Trusted proxy", "127.0.0.1:40000", "192.0.2.10", "192.0.2.10"},
+		{"Trusted proxy prefix", "[fd00::2]:40000", "2001:db8::10", "2001:db8::10"},
+		{"Trusted proxy, no header", "127.0.0.1:40000", "", "127.0.0.1"},
+		{"Untrusted client", "192.0.2.20:40000", "192.0.2.10", "192.0.2.20"},
+		{"Untrusted IPv6 client", "[2001:db8::20]:40000", "192.0.2.10", "2001:db8::20"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.header != "" {
+			r.Header.Set("X-Forwarded-For", tt.header)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != tt.want {
+			t.Errorf("%s: remote address %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// nginx on localhost, the default
+	realIP, err = trustedRealIP(defaultTrustedProxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = remoteAddr(r)
+	}))
+	for _, remote := range []string{"127.0.0.1:40000", "[::1]:40000"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Real-IP", "192.0.2.10")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != "192.0.2.10" {
+			t.Errorf("default, proxy %s: remote address %q, want 192.0.2.10", remote, got)
+		}
+	}
+
+	if _, err := trustedRealIP([]string{"proxy.example.com"}); err == nil {
+		t.Errorf("trustedRealIP(proxy.example.com) = nil, want an error")
+	}
+}
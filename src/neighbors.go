@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Neighbor attributes, states and flags, from linux/neighbour.h, in
+// addition to the ones of the bridge FDB
+const (
+	ndaDst        = 1
+	nudIncomplete = 0x01
+	nudReachable  = 0x02
+	nudStale      = 0x04
+	nudDelay      = 0x08
+	nudProbe      = 0x10
+	nudFailed     = 0x20
+	ntfMaster     = 0x04
+	ntfRouter     = 0x80
+)
+
+// Names of neighbor states, as ip neigh shows them
+var nudStates = []struct {
+	state uint16
+	name  string
+}{
+	{nudIncomplete, "INCOMPLETE"},
+	{nudReachable, "REACHABLE"},
+	{nudStale, "STALE"},
+	{nudDelay, "DELAY"},
+	{nudProbe, "PROBE"},
+	{nudFailed, "FAILED"},
+	{nudNoarp, "NOARP"},
+	{nudPermanent, "PERMANENT"},
+}
+
+// Neighbor is an entry of the ARP, or IPv6 neighbor discovery, cache
+type Neighbor struct {
+	Address string
+	MAC     string
+	Device  string
+	State   string
+	Router  bool
+	Vendor  string
+}
+
+// StateClass returns the badge colour of the state of a neighbor
+func (n Neighbor) StateClass() string {
+	switch n.State {
+	case "REACHABLE":
+		return "bg-success"
+	case "STALE", "DELAY", "PROBE":
+		return "bg-warning text-dark"
+	case "FAILED", "INCOMPLETE":
+		return "bg-danger"
+	}
+	return "bg-secondary"
+}
+
+// NeighborFDBEntry is an entry of the forwarding database of a bridge
+type NeighborFDBEntry struct {
+	FDBEntry
+	Bridge  string
+	Vendor  string
+	Dynamic bool
+}
+
+// NeighborsInfo holds data for the neighbors page
+type NeighborsInfo struct {
+	Query   string
+	IPv4    []Neighbor
+	IPv6    []Neighbor
+	FDB     []NeighborFDBEntry
+	Message string
+	Error   string
+}
+
+// nudName returns the name of a neighbor state
+func nudName(state uint16) string {
+	for _, s := range nudStates {
+		if state&s.state != 0 {
+			return s.name
+		}
+	}
+	return "NONE"
+}
+
+// parseNeighbors parses an RTM_GETNEIGH dump of the ARP and neighbor
+// discovery caches.  NOARP entries, e.g., of multicast and loopback,
+// are skipped.
+func parseNeighbors(msgs []syscall.NetlinkMessage, names map[int]string) []Neighbor {
+	var neighbors []Neighbor
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < sizeofNdMsg {
+			continue
+		}
+
+		family := m.Data[0]
+		if family != syscall.AF_INET && family != syscall.AF_INET6 {
+			continue
+		}
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		if state&nudNoarp != 0 {
+			continue
+		}
+
+		n := Neighbor{
+			Device: names[int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))],
+			State:  nudName(state),
+			Router: m.Data[10]&ntfRouter != 0,
+		}
+		for _, attr := range parseAttrs(m.Data[sizeofNdMsg:]) {
+			switch attr.Type {
+			case ndaDst:
+				n.Address = net.IP(attr.Value).String()
+			case ndaLladdr:
+				n.MAC = net.HardwareAddr(attr.Value).String()
+			}
+		}
+		if n.Address == "" {
+			continue
+		}
+		n.Vendor = lookupVendor(n.MAC)
+
+		neighbors = append(neighbors, n)
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		a, _ := netip.ParseAddr(neighbors[i].Address)
+		b, _ := netip.ParseAddr(neighbors[j].Address)
+		if a != b {
+			return a.Less(b)
+		}
+		return neighbors[i].Device < neighbors[j].Device
+	})
+
+	return neighbors
+}
+
+// getNeighbors returns the ARP and neighbor discovery caches
+func getNeighbors() (ipv4, ipv6 []Neighbor, err error) {
+	_, names, err := getLinks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgs, err := netlinkDump(syscall.RTM_GETNEIGH, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, n := range parseNeighbors(msgs, names) {
+		if strings.Contains(n.Address, ":") {
+			ipv6 = append(ipv6, n)
+		} else {
+			ipv4 = append(ipv4, n)
+		}
+	}
+
+	return ipv4, ipv6, nil
+}
+
+// getFDB returns the forwarding databases of all bridges
+func getFDB() ([]NeighborFDBEntry, error) {
+	_, names, err := getLinks()
+	if err != nil {
+		return nil, err
+	}
+
+	fdb, err := bridgeFDB(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []NeighborFDBEntry
+	for master, bridge := range fdb {
+		for _, e := range bridge {
+			entries = append(entries, NeighborFDBEntry{
+				FDBEntry: e,
+				Bridge:   names[master],
+				Vendor:   lookupVendor(e.MAC),
+				Dynamic:  len(e.Flags) > 0 && e.Flags[0] == "dynamic",
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Bridge < entries[j].Bridge
+	})
+
+	return entries, nil
+}
+
+// ndMsg returns a struct ndmsg, for requests on an entry
+func ndMsg(family uint8, index int, flags uint8) []byte {
+	msg := make([]byte, sizeofNdMsg)
+	msg[0] = family
+	binary.NativeEndian.PutUint32(msg[4:8], uint32(index))
+	msg[10] = flags
+	return msg
+}
+
+// flushNeighbor removes an entry from the ARP, or neighbor discovery,
+// cache of an interface
+func flushNeighbor(ifname, address string) error {
+	iface, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("invalid address %q", address)
+	}
+
+	family, dst := uint8(syscall.AF_INET6), []byte(ip.To16())
+	if ip4 := ip.To4(); ip4 != nil {
+		family, dst = syscall.AF_INET, ip4
+	}
+
+	msg := appendAttr(ndMsg(family, iface.Index, 0), ndaDst, dst)
+	_, err = netlinkRequest(syscall.RTM_DELNEIGH, 0, msg)
+	return err
+}
+
+// flushFDBEntry removes a learned entry from the forwarding database
+// of the bridge of a port
+func flushFDBEntry(port, mac string, vlan int) error {
+	iface, err := net.InterfaceByName(port)
+	if err != nil {
+		return err
+	}
+
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+
+	msg := appendAttr(ndMsg(syscall.AF_BRIDGE, iface.Index, ntfMaster), ndaLladdr, hwaddr)
+	if vlan != 0 {
+		vid := make([]byte, 2)
+		binary.NativeEndian.PutUint16(vid, uint16(vlan))
+		msg = appendAttr(msg, ndaVlan, vid)
+	}
+
+	_, err = netlinkRequest(syscall.RTM_DELNEIGH, 0, msg)
+	return err
+}
+
+// matchNeighbor returns if any field of an entry contains the query
+func matchNeighbor(query string, fields ...string) bool {
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderNeighbors renders the neighbors page, filtered by the query
+func renderNeighbors(w http.ResponseWriter, r *http.Request, info *NeighborsInfo) {
+	info.Query = strings.TrimSpace(r.FormValue("q"))
+	query := strings.ToLower(info.Query)
+
+	ipv4, ipv6, err := getNeighbors()
+	if err != nil {
+		log.Printf("Error getting neighbors: %v", err)
+		info.Error = "Failed to get the neighbor tables"
+	}
+	for _, n := range ipv4 {
+		if matchNeighbor(query, n.Address, n.MAC, n.Device, n.State, n.Vendor) {
+			info.IPv4 = append(info.IPv4, n)
+		}
+	}
+	for _, n := range ipv6 {
+		if matchNeighbor(query, n.Address, n.MAC, n.Device, n.State, n.Vendor) {
+			info.IPv6 = append(info.IPv6, n)
+		}
+	}
+
+	fdb, err := getFDB()
+	if err != nil {
+		log.Printf("Error getting bridge FDB: %v", err)
+	}
+	for _, e := range fdb {
+		if matchNeighbor(query, e.MAC, e.Port, e.Bridge, e.Vendor, strconv.Itoa(e.VLAN)) {
+			info.FDB = append(info.FDB, e)
+		}
+	}
+
+	renderPage(w, r, "neighbors", info)
+}
+
+// neighborsHandler shows the ARP and neighbor discovery caches, and
+// the bridge FDB
+func neighborsHandler(w http.ResponseWriter, r *http.Request) {
+	renderNeighbors(w, r, &NeighborsInfo{})
+}
+
+// flushNeighborHandler removes a neighbor, or a bridge FDB entry
+func flushNeighborHandler(w http.ResponseWriter, r *http.Request) {
+	ifname := r.FormValue("ifname")
+
+	var err error
+	var entry string
+	if mac := r.FormValue("mac"); mac != "" {
+		vlan, _ := strconv.Atoi(r.FormValue("vlan"))
+		entry = fmt.Sprintf("%s on %s", mac, ifname)
+		err = flushFDBEntry(ifname, mac, vlan)
+	} else {
+		entry = fmt.Sprintf("%s on %s", r.FormValue("address"), ifname)
+		err = flushNeighbor(ifname, r.FormValue("address"))
+	}
+	if err != nil {
+		log.Printf("Error flushing %s: %v", entry, err)
+		renderNeighbors(w, r, &NeighborsInfo{Error: fmt.Sprintf("Failed to flush %s: %v", entry, err)})
+		return
+	}
+
+	log.Printf("Neighbor %s flushed by %s", entry, getUsername(r))
+	renderNeighbors(w, r, &NeighborsInfo{Message: fmt.Sprintf("Flushed %s", entry)})
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// netlinkAttr is a route attribute of a netlink message
+type netlinkAttr struct {
+	Type  uint16
+	Value []byte
+}
+
+// Attribute type flags, for nested and network byte order attributes
+const nlaTypeMask = 0x3fff
+
+// IFLA_INFO_KIND, the kind of a link in IFLA_LINKINFO, e.g., bridge,
+// and IFLA_INFO_DATA, with IFLA_VRF_TABLE of VRFs and IFLA_VLAN_ID of
+// VLANs
+const (
+	iflaInfoKind = 1
+	iflaInfoData = 2
+	iflaVrfTable = 1
+	iflaVlanID   = 1
+)
+
+// RTA_VIA, a gateway of another address family, and the size of
+// struct rtnexthop, before its attributes
+const (
+	rtaVia           = 18
+	sizeofRtNexthop  = 8
+	sizeofFibRuleHdr = 12
+)
+
+// Attributes, actions and flags of policy rules, from linux/fib_rules.h
+const (
+	fraDst      = 1
+	fraSrc      = 2
+	fraIifname  = 3
+	fraGoto     = 4
+	fraPriority = 6
+	fraFwmark   = 10
+	fraTable    = 15
+	fraFwmask   = 16
+	fraOifname  = 17
+	fraL3mdev   = 19
+
+	frActToTbl = 1
+	frActGoto  = 2
+	frActNop   = 3
+
+	fibRuleInvert = 0x2
+)
+
+// Names of the actions of policy rules, other than lookup and goto
+var ruleActions = map[uint8]string{
+	frActNop:                "nop",
+	syscall.RTN_BLACKHOLE:   "blackhole",
+	syscall.RTN_UNREACHABLE: "unreachable",
+	syscall.RTN_PROHIBIT:    "prohibit",
+}
+
+// Sizes of the message headers, before the attributes
+const (
+	sizeofIfInfomsg = syscall.SizeofIfInfomsg
+	sizeofIfAddrmsg = syscall.SizeofIfAddrmsg
+	sizeofRtMsg     = syscall.SizeofRtMsg
+)
+
+// Route flags, from the route and its next hops, as shown by ip
+var routeFlags = []struct {
+	flag uint32
+	name string
+}{
+	{syscall.RTNH_F_DEAD, "dead"},
+	{syscall.RTNH_F_PERVASIVE, "pervasive"},
+	{syscall.RTNH_F_ONLINK, "onlink"},
+	{0x08, "offload"},
+	{0x10, "linkdown"},
+	{0x20, "unresolved"},
+	{syscall.RTM_F_NOTIFY, "notify"},
+}
+
+// Names of route protocols, as in /etc/iproute2/rt_protos, and the
+// rt_protos.d/frr.conf of FRR, where static routes are 196
+var routeProtocols = map[uint8]string{
+	syscall.RTPROT_UNSPEC:   "unspec",
+	syscall.RTPROT_REDIRECT: "redirect",
+	syscall.RTPROT_KERNEL:   "kernel",
+	syscall.RTPROT_BOOT:     "boot",
+	syscall.RTPROT_STATIC:   "static",
+	syscall.RTPROT_GATED:    "gated",
+	syscall.RTPROT_RA:       "ra",
+	syscall.RTPROT_MRT:      "mrt",
+	syscall.RTPROT_ZEBRA:    "zebra",
+	syscall.RTPROT_BIRD:     "bird",
+	syscall.RTPROT_DNROUTED: "dnrouted",
+	syscall.RTPROT_XORP:     "xorp",
+	syscall.RTPROT_NTK:      "ntk",
+	syscall.RTPROT_DHCP:     "dhcp",
+	42:                      "babel",
+	186:                     "bgp",
+	187:                     "isis",
+	188:                     "ospf",
+	189:                     "rip",
+	190:                     "ripng",
+	191:                     "nhrp",
+	192:                     "eigrp",
+	193:                     "ldp",
+	194:                     "sharp",
+	195:                     "pbr",
+	196:                     "static",
+	197:                     "openfabric",
+}
+
+// Names of route scopes
+var routeScopes = map[uint8]string{
+	syscall.RT_SCOPE_UNIVERSE: "global",
+	syscall.RT_SCOPE_SITE:     "site",
+	syscall.RT_SCOPE_LINK:     "link",
+	syscall.RT_SCOPE_HOST:     "host",
+	syscall.RT_SCOPE_NOWHERE:  "nowhere",
+}
+
+// Names of route types, unicast routes have no type shown
+var routeTypes = map[uint8]string{
+	syscall.RTN_LOCAL:       "local",
+	syscall.RTN_BROADCAST:   "broadcast",
+	syscall.RTN_ANYCAST:     "anycast",
+	syscall.RTN_MULTICAST:   "multicast",
+	syscall.RTN_BLACKHOLE:   "blackhole",
+	syscall.RTN_UNREACHABLE: "unreachable",
+	syscall.RTN_PROHIBIT:    "prohibit",
+	syscall.RTN_THROW:       "throw",
+	syscall.RTN_NAT:         "nat",
+}
+
+// Names of link operational states, IFLA_OPERSTATE
+var operStates = []string{"UNKNOWN", "NOTPRESENT", "DOWN", "LOWERLAYERDOWN", "TESTING", "DORMANT", "UP"}
+
+// netlinkDump dumps a routing table, links, addresses or routes, of an
+// address family from the kernel
+func netlinkDump(request, family int) ([]syscall.NetlinkMessage, error) {
+	data, err := syscall.NetlinkRIB(request, family)
+	if err != nil {
+		return nil, fmt.Errorf("netlink request failed: %w", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse netlink messages: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// netlinkRequest sends a request, a message header followed by body,
+// and returns the replies.  Dump requests return all messages of the
+// dump, other requests are acknowledged, and return nothing.
+func netlinkRequest(typ, flags uint16, body []byte) ([]syscall.NetlinkMessage, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("netlink request failed: %w", err)
+	}
+	defer syscall.Close(s)
+
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(s, sa); err != nil {
+		return nil, fmt.Errorf("netlink request failed: %w", err)
+	}
+
+	req := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(body))
+	binary.NativeEndian.PutUint32(req[0:4], uint32(syscall.NLMSG_HDRLEN+len(body)))
+	binary.NativeEndian.PutUint16(req[4:6], typ)
+	binary.NativeEndian.PutUint16(req[6:8], flags|syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(req[8:12], 1)
+	req = append(req, body...)
+
+	if err := syscall.Sendto(s, req, 0, sa); err != nil {
+		return nil, fmt.Errorf("netlink request failed: %w", err)
+	}
+
+	var replies []syscall.NetlinkMessage
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(s, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("netlink request failed: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse netlink messages: %w", err)
+		}
+
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return replies, nil
+			case syscall.NLMSG_ERROR:
+				// An error of 0 is the acknowledgement
+				if len(m.Data) < 4 {
+					return nil, syscall.EINVAL
+				}
+				if errno := -int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(errno)
+				}
+				return replies, nil
+			}
+
+			// Copy, the buffer is reused for the next replies
+			m.Data = append([]byte(nil), m.Data...)
+			replies = append(replies, m)
+		}
+	}
+}
+
+// appendAttr appends an attribute, padded, to a message body
+func appendAttr(b []byte, typ uint16, value []byte) []byte {
+	length := syscall.SizeofRtAttr + len(value)
+	b = binary.NativeEndian.AppendUint16(b, uint16(length))
+	b = binary.NativeEndian.AppendUint16(b, typ)
+	b = append(b, value...)
+
+	for length%syscall.RTA_ALIGNTO != 0 {
+		b = append(b, 0)
+		length++
+	}
+	return b
+}
+
+// parseAttrs parses the attributes following a message header
+func parseAttrs(b []byte) []netlinkAttr {
+	var attrs []netlinkAttr
+
+	for len(b) >= syscall.SizeofRtAttr {
+		length := int(binary.NativeEndian.Uint16(b[0:2]))
+		if length < syscall.SizeofRtAttr || length > len(b) {
+			break
+		}
+
+		attrs = append(attrs, netlinkAttr{
+			Type:  binary.NativeEndian.Uint16(b[2:4]) & nlaTypeMask,
+			Value: b[syscall.SizeofRtAttr:length],
+		})
+
+		aligned := (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+
+	return attrs
+}
+
+// attrString returns a NUL terminated string attribute
+func attrString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// attrUint32 returns a 32-bit attribute, or 0
+func attrUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.NativeEndian.Uint32(b)
+}
+
+// link is a network interface, as dumped by RTM_GETLINK
+type link struct {
+	Index  int
+	Name   string
+	State  string
+	HWAddr string
+	Master int
+	Link   int
+	Kind   string
+	VID    int
+	Table  uint32
+	Stats  LinkStats
+}
+
+// parseLinks parses an RTM_GETLINK dump
+func parseLinks(msgs []syscall.NetlinkMessage) []link {
+	var links []link
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWLINK || len(m.Data) < sizeofIfInfomsg {
+			continue
+		}
+
+		l := link{
+			Index: int(int32(binary.NativeEndian.Uint32(m.Data[4:8]))),
+			State: operStates[0],
+		}
+		for _, attr := range parseAttrs(m.Data[sizeofIfInfomsg:]) {
+			switch attr.Type {
+			case syscall.IFLA_IFNAME:
+				l.Name = attrString(attr.Value)
+			case syscall.IFLA_ADDRESS:
+				l.HWAddr = net.HardwareAddr(attr.Value).String()
+			case syscall.IFLA_MASTER:
+				l.Master = int(attrUint32(attr.Value))
+			case syscall.IFLA_LINK:
+				l.Link = int(int32(attrUint32(attr.Value)))
+			case iflaStats64:
+				l.Stats = parseLinkStats(attr.Value)
+			case syscall.IFLA_LINKINFO:
+				for _, info := range parseAttrs(attr.Value) {
+					switch info.Type {
+					case iflaInfoKind:
+						l.Kind = attrString(info.Value)
+					case iflaInfoData:
+						for _, data := range parseAttrs(info.Value) {
+							switch {
+							case l.Kind == "vrf" && data.Type == iflaVrfTable:
+								l.Table = attrUint32(data.Value)
+							case l.Kind == "vlan" && data.Type == iflaVlanID && len(data.Value) >= 2:
+								l.VID = int(binary.NativeEndian.Uint16(data.Value))
+							}
+						}
+					}
+				}
+			case syscall.IFLA_OPERSTATE:
+				if len(attr.Value) > 0 && int(attr.Value[0]) < len(operStates) {
+					l.State = operStates[attr.Value[0]]
+				}
+			}
+		}
+
+		links = append(links, l)
+	}
+
+	return links
+}
+
+// addr is an interface address, as dumped by RTM_GETADDR
+type addr struct {
+	Index int
+	AddrInfo
+}
+
+// parseAddrs parses an RTM_GETADDR dump
+func parseAddrs(msgs []syscall.NetlinkMessage) []addr {
+	var addrs []addr
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWADDR || len(m.Data) < sizeofIfAddrmsg {
+			continue
+		}
+
+		a := addr{Index: int(binary.NativeEndian.Uint32(m.Data[4:8]))}
+		a.PrefixLen = int(m.Data[1])
+
+		// Point-to-point links have both, IFA_LOCAL is our end
+		var address, local net.IP
+		for _, attr := range parseAttrs(m.Data[sizeofIfAddrmsg:]) {
+			switch attr.Type {
+			case syscall.IFA_ADDRESS:
+				address = net.IP(attr.Value)
+			case syscall.IFA_LOCAL:
+				local = net.IP(attr.Value)
+			}
+		}
+		if local != nil {
+			address = local
+		}
+		if address == nil {
+			continue
+		}
+
+		a.Address = address.String()
+		addrs = append(addrs, a)
+	}
+
+	return addrs
+}
+
+// tableName returns the name of a routing table, as ip shows it
+func tableName(table uint32) string {
+	switch table {
+	case syscall.RT_TABLE_MAIN:
+		return "main"
+	case syscall.RT_TABLE_LOCAL:
+		return "local"
+	case syscall.RT_TABLE_DEFAULT:
+		return "default"
+	}
+	return strconv.FormatUint(uint64(table), 10)
+}
+
+// nameOf returns the name of a value, or the number if unknown
+func nameOf(names map[uint8]string, value uint8) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return strconv.Itoa(int(value))
+}
+
+// flagNames returns the names of route flags
+func flagNames(flags uint32) []string {
+	var names []string
+	for _, f := range routeFlags {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// parseRoutes parses an RTM_GETROUTE dump, devices are named by the
+// index to name map.  Cloned routes, i.e., the IPv6 route cache, are
+// skipped.
+func parseRoutes(msgs []syscall.NetlinkMessage, names map[int]string) []Route {
+	var routes []Route
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < sizeofRtMsg {
+			continue
+		}
+
+		family, dstLen := m.Data[0], int(m.Data[1])
+		table := uint32(m.Data[4])
+		flags := binary.NativeEndian.Uint32(m.Data[8:12])
+		if flags&syscall.RTM_F_CLONED != 0 {
+			continue
+		}
+
+		r := Route{
+			Protocol: nameOf(routeProtocols, m.Data[5]),
+			Scope:    nameOf(routeScopes, m.Data[6]),
+			Flags:    flagNames(flags),
+		}
+		if m.Data[7] != syscall.RTN_UNICAST {
+			r.Type = nameOf(routeTypes, m.Data[7])
+		}
+
+		for _, attr := range parseAttrs(m.Data[sizeofRtMsg:]) {
+			switch attr.Type {
+			case syscall.RTA_DST:
+				r.Destination = formatPrefix(net.IP(attr.Value), dstLen, family)
+			case syscall.RTA_GATEWAY:
+				r.Gateway = net.IP(attr.Value).String()
+			case rtaVia:
+				r.Gateway = parseVia(attr.Value)
+			case syscall.RTA_MULTIPATH:
+				r.NextHops = parseNextHops(attr.Value, names)
+			case syscall.RTA_OIF:
+				r.Device = names[int(attrUint32(attr.Value))]
+			case syscall.RTA_PRIORITY:
+				r.Metric = int(attrUint32(attr.Value))
+			case syscall.RTA_TABLE:
+				table = attrUint32(attr.Value)
+			}
+		}
+
+		if r.Destination == "" {
+			r.Destination = "default"
+			if dstLen > 0 {
+				r.Destination = fmt.Sprintf("0/%d", dstLen)
+			}
+		}
+		r.Table = tableName(table)
+
+		// Routes with all their next hops down are not used
+		r.Inactive = isDown(r.Flags)
+		if len(r.NextHops) > 0 {
+			r.Inactive = true
+			for _, nh := range r.NextHops {
+				r.Inactive = r.Inactive && isDown(nh.Flags)
+			}
+			r.Gateway, r.Device = r.NextHops[0].Gateway, r.NextHops[0].Device
+		}
+
+		routes = append(routes, r)
+	}
+
+	return routes
+}
+
+// isDown returns if route flags mark a next hop as not used
+func isDown(flags []string) bool {
+	for _, flag := range flags {
+		if flag == "dead" || flag == "linkdown" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVia returns the gateway of an RTA_VIA attribute, struct rtvia
+func parseVia(b []byte) string {
+	if len(b) < 2 {
+		return ""
+	}
+	return net.IP(b[2:]).String()
+}
+
+// parseNextHops parses the next hops of RTA_MULTIPATH, a list of
+// struct rtnexthop, each followed by its attributes
+func parseNextHops(b []byte, names map[int]string) []NextHop {
+	var hops []NextHop
+
+	for len(b) >= sizeofRtNexthop {
+		length := int(binary.NativeEndian.Uint16(b[0:2]))
+		if length < sizeofRtNexthop || length > len(b) {
+			break
+		}
+
+		nh := NextHop{
+			Flags:  flagNames(uint32(b[2])),
+			Weight: int(b[3]) + 1,
+			Device: names[int(int32(binary.NativeEndian.Uint32(b[4:8])))],
+		}
+		for _, attr := range parseAttrs(b[sizeofRtNexthop:length]) {
+			switch attr.Type {
+			case syscall.RTA_GATEWAY:
+				nh.Gateway = net.IP(attr.Value).String()
+			case rtaVia:
+				nh.Gateway = parseVia(attr.Value)
+			}
+		}
+		hops = append(hops, nh)
+
+		aligned := (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+
+	return hops
+}
+
+// parseRules parses an RTM_GETRULE dump, rules are formatted as ip
+// rule shows them, tables named by the table to name function
+func parseRules(msgs []syscall.NetlinkMessage, tables func(uint32) string) []Rule {
+	var rules []Rule
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWRULE || len(m.Data) < sizeofFibRuleHdr {
+			continue
+		}
+
+		family, dstLen, srcLen := m.Data[0], int(m.Data[1]), int(m.Data[2])
+		table, action := uint32(m.Data[4]), m.Data[7]
+		flags := binary.NativeEndian.Uint32(m.Data[8:12])
+
+		var rule Rule
+		var from, to, iif, oif, fwmark, mask string
+		var target uint32
+		l3mdev := false
+		for _, attr := range parseAttrs(m.Data[sizeofFibRuleHdr:]) {
+			switch attr.Type {
+			case fraPriority:
+				rule.Priority = int(attrUint32(attr.Value))
+			case fraSrc:
+				from = formatPrefix(net.IP(attr.Value), srcLen, family)
+			case fraDst:
+				to = formatPrefix(net.IP(attr.Value), dstLen, family)
+			case fraIifname:
+				iif = attrString(attr.Value)
+			case fraOifname:
+				oif = attrString(attr.Value)
+			case fraFwmark:
+				fwmark = fmt.Sprintf("%#x", attrUint32(attr.Value))
+			case fraFwmask:
+				if m := attrUint32(attr.Value); m != 0xffffffff {
+					mask = fmt.Sprintf("/%#x", m)
+				}
+			case fraTable:
+				table = attrUint32(attr.Value)
+			case fraGoto:
+				target = attrUint32(attr.Value)
+			case fraL3mdev:
+				l3mdev = len(attr.Value) > 0 && attr.Value[0] != 0
+			}
+		}
+
+		var b strings.Builder
+		if flags&fibRuleInvert != 0 {
+			b.WriteString("not ")
+		}
+		if from == "" {
+			from = "all"
+		}
+		fmt.Fprintf(&b, "from %s", from)
+		if to != "" {
+			fmt.Fprintf(&b, " to %s", to)
+		}
+		if fwmark != "" {
+			fmt.Fprintf(&b, " fwmark %s%s", fwmark, mask)
+		}
+		if iif != "" {
+			fmt.Fprintf(&b, " iif %s", iif)
+		}
+		if oif != "" {
+			fmt.Fprintf(&b, " oif %s", oif)
+		}
+
+		switch {
+		case l3mdev:
+			b.WriteString(" lookup [l3mdev-table]")
+		case action == frActToTbl:
+			rule.Table = tables(table)
+			fmt.Fprintf(&b, " lookup %s", rule.Table)
+		case action == frActGoto:
+			fmt.Fprintf(&b, " goto %d", target)
+		default:
+			fmt.Fprintf(&b, " %s", nameOf(ruleActions, action))
+		}
+		rule.Rule = b.String()
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// formatPrefix returns a destination prefix as ip shows it, host
+// routes without the prefix length
+func formatPrefix(ip net.IP, length int, family uint8) string {
+	bits := 32
+	if family == syscall.AF_INET6 {
+		bits = 128
+	}
+
+	if length == bits {
+		return ip.String()
+	}
+	return fmt.Sprintf("%s/%d", ip, length)
+}
+
+// getLinks returns the network interfaces, and a map of their names
+// by index
+func getLinks() ([]link, map[int]string, error) {
+	msgs, err := netlinkDump(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	links := parseLinks(msgs)
+	names := make(map[int]string, len(links))
+	for _, l := range links {
+		names[l.Index] = l.Name
+	}
+
+	return links, names, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// readDump returns the messages of a netlink dump in testdata, recorded
+// by testdata/record.go on a little-endian host
+func readDump(t *testing.T, files ...string) []syscall.NetlinkMessage {
+	t.Helper()
+
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("netlink dumps are recorded in little-endian")
+	}
+
+	var msgs []syscall.NetlinkMessage
+	for _, file := range files {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := syscall.ParseNetlinkMessage(b)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		msgs = append(msgs, m...)
+	}
+	return msgs
+}
+
+// readLinks returns the links of the dumps, and their names by index
+func readLinks(t *testing.T) ([]link, map[int]string) {
+	links := parseLinks(readDump(t, "links.bin", "link-vlan.bin"))
+
+	names := make(map[int]string)
+	for _, l := range links {
+		names[l.Index] = l.Name
+	}
+	return links, names
+}
+
+func TestLinkInterfaces(t *testing.T) {
+	links, names := readLinks(t)
+	ifaces := linkInterfaces(links, names, parseAddrs(readDump(t, "addrs.bin")))
+
+	want := map[string]Interface{
+		"eth0": {
+			Name:   "eth0",
+			State:  "UP",
+			HWAddr: "02:fc:00:00:00:01",
+			Addresses: []AddrInfo{
+				{Address: "192.0.2.2", PrefixLen: 24},
+				{Address: "fd00::2", PrefixLen: 64},
+				{Address: "fe80::fc:ff:fe00:1", PrefixLen: 64},
+			},
+		},
+		"eth0.10": {
+			Name:   "eth0.10",
+			Type:   "vlan",
+			State:  "UP",
+			HWAddr: "02:fc:00:00:00:01",
+			Lower:  "eth0",
+			VID:    10,
+		},
+		"br0": {
+			Name:   "br0",
+			Type:   "bridge",
+			State:  "UP",
+			HWAddr: "1a:ca:03:1c:f3:1f",
+			Addresses: []AddrInfo{
+				{Address: "10.0.0.1", PrefixLen: 24},
+				{Address: "2001:db8::1", PrefixLen: 64},
+				{Address: "fe80::18ca:3ff:fe1c:f31f", PrefixLen: 64},
+			},
+		},
+		"veth0": {
+			Name:   "veth0",
+			Type:   "veth",
+			State:  "UP",
+			HWAddr: "1a:ca:03:1c:f3:1f",
+			Addresses: []AddrInfo{
+				{Address: "fe80::18ca:3ff:fe1c:f31f", PrefixLen: 64},
+			},
+			Master: "br0",
+		},
+		"veth2": {
+			Name:   "veth2",
+			Type:   "veth",
+			State:  "LOWERLAYERDOWN",
+			HWAddr: "f6:ea:9e:2c:a1:48",
+		},
+	}
+
+	found := 0
+	for _, iface := range ifaces {
+		w, ok := want[iface.Name]
+		if !ok {
+			continue
+		}
+		found++
+		if !reflect.DeepEqual(iface, w) {
+			t.Errorf("interface %s:\n got %+v\nwant %+v", iface.Name, iface, w)
+		}
+	}
+	if found != len(want) {
+		t.Errorf("found %d of the %d interfaces, got %+v", found, len(want), ifaces)
+	}
+}
+
+// findRoute returns the route to a destination in a table
+func findRoute(routes []Route, table, destination string) *Route {
+	for i := range routes {
+		if routes[i].Table == table && routes[i].Destination == destination {
+			return &routes[i]
+		}
+	}
+	return nil
+}
+
+func TestParseRoutes(t *testing.T) {
+	_, names := readLinks(t)
+	routes := parseRoutes(readDump(t, "routes4.bin", "routes6.bin"), names)
+
+	tests := []struct {
+		table string
+		want  Route
+	}{
+		{
+			table: "main",
+			want: Route{
+				Destination: "default",
+				Gateway:     "192.0.2.1",
+				Device:      "eth0",
+				Protocol:    "boot",
+				Scope:       "global",
+			},
+		},
+		{
+			table: "main",
+			want: Route{
+				Destination: "10.0.0.0/24",
+				Device:      "br0",
+				Protocol:    "kernel",
+				Scope:       "link",
+			},
+		},
+		{
+			table: "100",
+			want: Route{
+				Destination: "198.51.100.0/24",
+				Gateway:     "192.0.2.1",
+				Device:      "eth0",
+				Protocol:    "boot",
+				Scope:       "global",
+			},
+		},
+		{
+			table: "100",
+			want: Route{
+				Destination: "192.0.2.128/25",
+				Device:      "veth2",
+				Protocol:    "boot",
+				Scope:       "link",
+				Flags:       []string{"linkdown"},
+				Inactive:    true,
+			},
+		},
+		{
+			table: "main",
+			want: Route{
+				Destination: "203.0.113.0/24",
+				Gateway:     "192.0.2.1",
+				Device:      "eth0",
+				Protocol:    "boot",
+				Scope:       "global",
+				NextHops: []NextHop{
+					{Gateway: "192.0.2.1", Device: "eth0", Weight: 1},
+					{Gateway: "192.0.2.3", Device: "eth0", Weight: 2},
+				},
+			},
+		},
+		{
+			table: "main",
+			want: Route{
+				Destination: "2001:db8:1::/48",
+				Gateway:     "fd00::1",
+				Device:      "eth0",
+				Protocol:    "boot",
+				Metric:      1024,
+				Scope:       "global",
+				NextHops: []NextHop{
+					{Gateway: "fd00::1", Device: "eth0", Weight: 1},
+					{Gateway: "fd00::3", Device: "eth0", Weight: 1},
+				},
+			},
+		},
+		{
+			table: "main",
+			want: Route{
+				Destination: "2001:db8::/64",
+				Device:      "br0",
+				Protocol:    "kernel",
+				Metric:      256,
+				Scope:       "global",
+			},
+		},
+		{
+			table: "local",
+			want: Route{
+				Destination: "2001:db8::1",
+				Device:      "br0",
+				Protocol:    "kernel",
+				Scope:       "global",
+				Type:        "local",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.want.Table = tt.table
+
+		got := findRoute(routes, tt.table, tt.want.Destination)
+		if got == nil {
+			t.Errorf("no route to %s in table %s", tt.want.Destination, tt.table)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("route to %s in table %s:\n got %+v\nwant %+v", tt.want.Destination, tt.table, *got, tt.want)
+		}
+	}
+}
+
+func TestMainRoutes(t *testing.T) {
+	_, names := readLinks(t)
+
+	for _, family := range []struct {
+		file string
+		ipv6 bool
+		want []string
+	}{
+		{"routes4.bin", false, []string{"default", "10.0.0.0/24", "192.0.2.0/24", "203.0.113.0/24"}},
+		{"routes6.bin", true, []string{"default", "2001:db8::/64", "2001:db8:1::/48", "fd00::/64", "fe80::/64"}},
+	} {
+		routes := mainRoutes(parseRoutes(readDump(t, family.file), names), family.ipv6)
+
+		seen := make(map[string]bool)
+		for _, r := range routes {
+			if r.Table != "main" {
+				t.Errorf("%s: route to %s in table %s", family.file, r.Destination, r.Table)
+			}
+			if r.Gateway == "" {
+				t.Errorf("%s: route to %s has no gateway", family.file, r.Destination)
+			}
+			seen[r.Destination] = true
+		}
+		for _, destination := range family.want {
+			if !seen[destination] {
+				t.Errorf("%s: no route to %s, got %+v", family.file, destination, routes)
+			}
+		}
+	}
+}
+
+func TestParseNextHops(t *testing.T) {
+	_, names := readLinks(t)
+
+	var got []NextHop
+	for _, m := range readDump(t, "routes4.bin") {
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < sizeofRtMsg {
+			continue
+		}
+		for _, attr := range parseAttrs(m.Data[sizeofRtMsg:]) {
+			if attr.Type == syscall.RTA_MULTIPATH {
+				got = append(got, parseNextHops(attr.Value, names)...)
+			}
+		}
+	}
+
+	want := []NextHop{
+		{Gateway: "192.0.2.1", Device: "eth0", Weight: 1},
+		{Gateway: "192.0.2.3", Device: "eth0", Weight: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNextHops() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules := parseRules(readDump(t, "rules4.bin"), tableName)
+
+	want := []Rule{
+		{Priority: 0, Rule: "from all lookup local", Table: "local"},
+		{Priority: 100, Rule: "from 10.0.0.0/24 lookup 100", Table: "100"},
+		{Priority: 32766, Rule: "from all lookup main", Table: "main"},
+		{Priority: 32767, Rule: "from all lookup default", Table: "default"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("parseRules() =\n%+v\nwant\n%+v", rules, want)
+	}
+}
+
+func TestRouteProtocolClass(t *testing.T) {
+	tests := []struct {
+		protocol uint8
+		name     string
+		class    string
+	}{
+		{syscall.RTPROT_STATIC, "static", "bg-primary"},
+		{196, "static", "bg-primary"},
+		{syscall.RTPROT_KERNEL, "kernel", "bg-secondary"},
+		{syscall.RTPROT_DHCP, "dhcp", "bg-info text-dark"},
+		{188, "ospf", "bg-warning text-dark"},
+		{190, "ripng", "bg-warning text-dark"},
+		{197, "openfabric", "bg-warning text-dark"},
+		{250, "250", "bg-warning text-dark"},
+	}
+
+	for _, tt := range tests {
+		r := Route{Protocol: nameOf(routeProtocols, tt.protocol)}
+		if r.Protocol != tt.name || r.ProtocolClass() != tt.class {
+			t.Errorf("protocol %d = %q, %q, want %q, %q", tt.protocol, r.Protocol, r.ProtocolClass(), tt.name, tt.class)
+		}
+	}
+}
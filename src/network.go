@@ -1,42 +1,86 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os/exec"
 	"sort"
+	"syscall"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type Interface struct {
-	Name      string     `json:"ifname"`
-	State     string     `json:"operstate,omitempty"`
-	HWAddr    string     `json:"address,omitempty"`
-	Addresses []AddrInfo `json:"addr_info,omitempty"`
+	Name        string     `json:"ifname"`
+	Type        string     `json:"type,omitempty"`
+	Description string     `json:"description,omitempty"`
+	AdminStatus string     `json:"admin_status,omitempty"`
+	State       string     `json:"operstate,omitempty"`
+	HWAddr      string     `json:"address,omitempty"`
+	Addresses   []AddrInfo `json:"addr_info,omitempty"`
+	Master      string     `json:"master,omitempty"`
+	Lower       string     `json:"link,omitempty"`
+	VID         int        `json:"vid,omitempty"`
 }
 
 type AddrInfo struct {
-	Address   string `json:"local"`
-	PrefixLen int    `json:"prefixlen"`
+	Address    string `json:"local"`
+	PrefixLen  int    `json:"prefixlen"`
+	Origin     string `json:"origin,omitempty"`
+	Configured bool   `json:"configured,omitempty"`
+	Inactive   bool   `json:"inactive,omitempty"`
+}
+
+// String returns the address in CIDR notation
+func (a AddrInfo) String() string {
+	return fmt.Sprintf("%s/%d", a.Address, a.PrefixLen)
 }
 
 type Route struct {
-	Destination string `json:"dst,omitempty"`
-	Gateway     string `json:"gateway,omitempty"`
-	Device      string `json:"dev,omitempty"`
-	Protocol    string `json:"protocol,omitempty"`
-	Metric      int    `json:"metric,omitempty"`
+	Destination string    `json:"dst,omitempty"`
+	Gateway     string    `json:"gateway,omitempty"`
+	Device      string    `json:"dev,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Metric      int       `json:"metric,omitempty"`
+	Table       string    `json:"table,omitempty"`
+	Scope       string    `json:"scope,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Flags       []string  `json:"flags,omitempty"`
+	NextHops    []NextHop `json:"nexthops,omitempty"`
+	Inactive    bool      `json:"inactive,omitempty"`
+	Age         string    `json:"age,omitempty"`
+}
+
+// NextHop is one of the next hops of a multipath (ECMP) route
+type NextHop struct {
+	Gateway string   `json:"gateway,omitempty"`
+	Device  string   `json:"dev,omitempty"`
+	Weight  int      `json:"weight,omitempty"`
+	Flags   []string `json:"flags,omitempty"`
 }
 
 type NetInfo struct {
 	Interfaces []Interface
+	Source     string
+	PortMap    *PortMap
 	Routes4    []Route
 	Routes6    []Route
 }
 
+// InterfaceInfo holds data for the details of an interface
+type InterfaceInfo struct {
+	Interface
+	Ethernet *EthernetInfo
+	Stats    *InterfaceStats
+	Config   *InterfaceConfig
+	Errors   map[string]string
+	Message  string
+	Error    string
+}
+
 func networkHandler(w http.ResponseWriter, r *http.Request) {
-	ifaces, err := getNetworkInterfaces()
+	ifaces, source, err := getInterfaces()
 	if err != nil {
 		log.Printf("Error getting network info: %v", err)
 		http.Error(w, "Failed to get network interfaces", http.StatusInternalServerError)
@@ -57,6 +101,8 @@ func networkHandler(w http.ResponseWriter, r *http.Request) {
 
 	info := &NetInfo{
 		Interfaces: ifaces,
+		Source:     source,
+		PortMap:    getPortMap(ifaces),
 		Routes4:    routes4,
 		Routes6:    routes6,
 	}
@@ -64,79 +110,165 @@ func networkHandler(w http.ResponseWriter, r *http.Request) {
 	renderPage(w, r, "net", info)
 }
 
+func interfaceHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := getInterfaceInfo(chi.URLParam(r, "ifname"))
+	if err != nil {
+		log.Printf("Error getting network info: %v", err)
+		http.Error(w, "Failed to get network interfaces", http.StatusInternalServerError)
+		return
+	}
+	if info == nil {
+		http.Error(w, "No such interface", http.StatusNotFound)
+		return
+	}
+
+	renderPage(w, r, "iface", info)
+}
+
+// getInterfaceInfo returns the details, and configuration, of an
+// interface, or nil if there is no such interface
+func getInterfaceInfo(name string) (*InterfaceInfo, error) {
+	ifaces, _, err := getInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &InterfaceInfo{}
+	for _, iface := range ifaces {
+		if iface.Name == name {
+			info.Interface = iface
+		}
+	}
+	if info.Name == "" {
+		return nil, nil
+	}
+
+	// Only Ethernet ports have link settings
+	if ethernet, err := getEthernetInfo(name); err == nil {
+		info.Ethernet = ethernet
+	}
+	info.Stats = getInterfaceStats(name)
+
+	// Without sysrepo there is nothing to edit
+	if cfg, err := getInterfaceConfig(name); err == nil {
+		info.Config = cfg
+	}
+
+	return info, nil
+}
+
+// getNetworkInterfaces returns the interfaces and their addresses from
+// the kernel
 func getNetworkInterfaces() ([]Interface, error) {
-	var interfaces []Interface
-	var loopback []Interface
-	var others []Interface
+	links, names, err := getLinks()
+	if err != nil {
+		return nil, err
+	}
 
-	cmd := exec.Command("ip", "-j", "addr")
-	output, err := cmd.Output()
+	msgs, err := netlinkDump(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute ip command: %w", err)
+		return nil, err
 	}
 
-	if err := json.Unmarshal(output, &interfaces); err != nil {
-		return nil, fmt.Errorf("failed to parse ip command output: %w", err)
+	return linkInterfaces(links, names, parseAddrs(msgs)), nil
+}
+
+// linkInterfaces returns the interfaces of links, with their addresses
+func linkInterfaces(links []link, names map[int]string, addrs []addr) []Interface {
+	var ifaces []Interface
+
+	byIndex := make(map[int][]AddrInfo)
+	for _, a := range addrs {
+		byIndex[a.Index] = append(byIndex[a.Index], a.AddrInfo)
 	}
 
-	// Simple sort: loopback first, then alphabetically
-	for _, iface := range interfaces {
-		if iface.Name == "lo" {
-			loopback = append(loopback, iface)
-		} else {
-			others = append(others, iface)
+	for _, l := range links {
+		lower := ""
+		if l.Kind == "vlan" {
+			lower = names[l.Link]
 		}
+
+		ifaces = append(ifaces, Interface{
+			Name:      l.Name,
+			Type:      l.Kind,
+			State:     l.State,
+			HWAddr:    l.HWAddr,
+			Addresses: byIndex[l.Index],
+			Master:    names[l.Master],
+			Lower:     lower,
+			VID:       l.VID,
+		})
 	}
 
-	// Sort other interfaces by name
-	sort.Slice(others, func(i, j int) bool {
-		return others[i].Name < others[j].Name
-	})
+	sortInterfaces(ifaces)
+	return ifaces
+}
 
-	return append(loopback, others...), nil
+// sortInterfaces sorts loopback first, then alphabetically
+func sortInterfaces(ifaces []Interface) {
+	sort.SliceStable(ifaces, func(i, j int) bool {
+		if ifaces[i].Name == "lo" {
+			return ifaces[j].Name != "lo"
+		}
+		if ifaces[j].Name == "lo" {
+			return false
+		}
+
+		return ifaces[i].Name < ifaces[j].Name
+	})
 }
 
+// getNetworkRoutes returns the routes of the main table, like ip route
 func getNetworkRoutes(ipv6 bool) ([]Route, error) {
-	var routes []Route
-	var cmd *exec.Cmd
-
+	family := syscall.AF_INET
 	if ipv6 {
-		cmd = exec.Command("ip", "-j", "-6", "route")
-	} else {
-		cmd = exec.Command("ip", "-j", "route")
+		family = syscall.AF_INET6
 	}
 
-	output, err := cmd.Output()
+	_, names, err := getLinks()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute ip command: %w", err)
+		return nil, err
 	}
 
-	if err := json.Unmarshal(output, &routes); err != nil {
-		return nil, fmt.Errorf("failed to parse ip command output: %w", err)
+	msgs, err := netlinkDump(syscall.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := range routes {
-		if routes[i].Gateway != "" {
+	return mainRoutes(parseRoutes(msgs, names), ipv6), nil
+}
+
+// mainRoutes returns the routes of the main table, sorted, with the
+// unspecified address as gateway of the routes without one
+func mainRoutes(all []Route, ipv6 bool) []Route {
+	var routes []Route
+	for _, route := range all {
+		if route.Table != "main" {
 			continue
 		}
 
-		if ipv6 {
-			routes[i].Gateway = "::"
-		} else {
-			routes[i].Gateway = "0.0.0.0"
+		if route.Gateway == "" {
+			if ipv6 {
+				route.Gateway = "::"
+			} else {
+				route.Gateway = "0.0.0.0"
+			}
 		}
+
+		routes = append(routes, route)
 	}
 
-	sort.Slice(routes, func(i, j int) bool {
-		if routes[i].Destination == "" || routes[i].Destination == "default" {
-			return true
-		}
-		if routes[j].Destination == "" || routes[j].Destination == "default" {
-			return false
-		}
+	sortRoutes(routes)
 
-		return routes[i].Destination < routes[j].Destination
-	})
+	return routes
+}
+
+// ipJSON runs an ip command, returning its JSON output
+func ipJSON(args ...string) ([]byte, error) {
+	output, err := exec.Command("ip", append([]string{"-j"}, args...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute ip command: %w", err)
+	}
 
-	return routes, nil
+	return output, nil
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Operation is a long-running, device-wide operation like an upgrade,
+// a reboot, or a factory reset.  Only one may run at a time.
+type Operation struct {
+	Kind       string
+	Owner      string
+	Started    time.Time
+	Cancelable bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+var (
+	currentOperation *Operation
+	operationMutex   sync.Mutex
+)
+
+// beginOperation starts an exclusive operation, or fails if another
+// operation is already in progress.  The operation is cancelable
+// until told otherwise, see SetCancelable().
+func beginOperation(kind, owner string) (*Operation, error) {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	if currentOperation != nil {
+		return nil, fmt.Errorf("%s in progress, please try again later", currentOperation.Description())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	currentOperation = &Operation{
+		Kind:       kind,
+		Owner:      owner,
+		Started:    time.Now(),
+		Cancelable: true,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	log.Printf("%s started by %s", kind, owner)
+
+	return currentOperation, nil
+}
+
+// End marks the operation as done, allowing another to start
+func (op *Operation) End() {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	op.cancel()
+	if currentOperation == op {
+		currentOperation = nil
+		log.Printf("%s started by %s ended", op.Kind, op.Owner)
+	}
+}
+
+// Context returns a context that is done when the operation is canceled
+func (op *Operation) Context() context.Context {
+	return op.ctx
+}
+
+// SetCancelable controls whether the operation can still be canceled,
+// e.g., an upgrade cannot be canceled once RAUC is writing to a slot
+func (op *Operation) SetCancelable(cancelable bool) {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	op.Cancelable = cancelable
+}
+
+// Description returns a human readable description of the operation
+func (op *Operation) Description() string {
+	return fmt.Sprintf("%s started by %s at %s", op.Kind, op.Owner, op.Started.Format("15:04:05"))
+}
+
+// Elapsed returns how long the operation has been running
+func (op *Operation) Elapsed() string {
+	return time.Since(op.Started).Round(time.Second).String()
+}
+
+// getOperation returns a snapshot of the current operation, or nil
+func getOperation() *Operation {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	if currentOperation == nil {
+		return nil
+	}
+
+	op := *currentOperation
+	return &op
+}
+
+// operationHandler returns the operation banner shown in the layout
+func operationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	renderFragment(w, "operation-banner", getOperation())
+}
+
+// cancelOperationHandler cancels the current operation, if possible
+func cancelOperationHandler(w http.ResponseWriter, r *http.Request) {
+	operationMutex.Lock()
+	op := currentOperation
+	if op == nil || !op.Cancelable {
+		operationMutex.Unlock()
+		http.Error(w, "No cancelable operation in progress", http.StatusConflict)
+		return
+	}
+	op.cancel()
+	operationMutex.Unlock()
+
+	log.Printf("%s canceled by user: %s", op.Description(), getUsername(r))
+
+	w.Header().Set("Cache-Control", "no-store")
+	renderFragment(w, "operation-banner", getOperation())
+}
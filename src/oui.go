@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Vendor databases, in the format of the IEEE oui.txt, gzipped if the
+// name ends with .gz.  The bundled ones are read first: the full
+// registry, fetched by make oui, and a selection of common vendors for
+// builds without it.  The ones of the system, when installed, add the
+// vendors they do not have.
+var (
+	ouiAssets = []string{"oui.txt.gz", "oui.txt"}
+	ouiFiles  = []string{
+		"/usr/share/ieee-data/oui.txt",
+		"/usr/share/hwdata/oui.txt",
+	}
+)
+
+var (
+	ouiOnce    sync.Once
+	ouiVendors map[uint32]string
+)
+
+// loadOUIFile reads the vendors of a database, lines like:
+//
+//	00-07-7C   (hex)		Westermo Teleindustri AB
+func loadOUIFile(path string, vendors map[uint32]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		prefix, vendor, ok := strings.Cut(scanner.Text(), "(hex)")
+		if !ok {
+			continue
+		}
+
+		oui, err := strconv.ParseUint(strings.ReplaceAll(strings.TrimSpace(prefix), "-", ""), 16, 24)
+		if err != nil {
+			continue
+		}
+		if _, ok := vendors[uint32(oui)]; !ok {
+			vendors[uint32(oui)] = strings.TrimSpace(vendor)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// loadOUI reads the vendor databases
+func loadOUI() {
+	ouiVendors = make(map[uint32]string)
+
+	var files []string
+	for _, name := range ouiAssets {
+		files = append(files, filepath.Join(staticPath, "assets", name))
+	}
+	for _, path := range append(files, ouiFiles...) {
+		err := loadOUIFile(path, ouiVendors)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Error reading vendor database %s: %v", path, err)
+		}
+	}
+}
+
+// lookupVendor returns the vendor of a MAC address, by its OUI, the
+// first three bytes
+func lookupVendor(mac string) string {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil || len(hwaddr) < 3 {
+		return ""
+	}
+
+	switch {
+	case hwaddr[0]&0x01 != 0:
+		return "Multicast"
+	case hwaddr[0]&0x02 != 0:
+		return "Locally administered"
+	}
+
+	ouiOnce.Do(loadOUI)
+	return ouiVendors[uint32(hwaddr[0])<<16|uint32(hwaddr[1])<<8|uint32(hwaddr[2])]
+}
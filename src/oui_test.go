@@ -0,0 +1,73 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const ouiSample = `OUI/MA-L                                                    Organization
+company_id                                                  Organization
+                                                            Address
+
+00-07-7C   (hex)		Westermo Teleindustri AB
+00077C     (base 16)		Westermo Teleindustri AB
+				Stora Sundby    SE-640 40
+				SE
+
+A0-36-9F   (hex)		Intel Corporate
+A0369F     (base 16)		Intel Corporate
+				Lot 8, Jalan Hi-Tech 2/3
+				Kulim  Kedah  09000
+				MY
+`
+
+func TestLoadOUIFile(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "oui.txt")
+	if err := os.WriteFile(plain, []byte(ouiSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := filepath.Join(dir, "oui.txt.gz")
+	f, err := os.Create(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(ouiSample))
+	gz.Close()
+	f.Close()
+
+	for _, path := range []string{plain, compressed} {
+		vendors := map[uint32]string{0xa0369f: "Intel"}
+		if err := loadOUIFile(path, vendors); err != nil {
+			t.Fatalf("loadOUIFile(%s): %v", path, err)
+		}
+
+		want := map[uint32]string{
+			0x00077c: "Westermo Teleindustri AB",
+			0xa0369f: "Intel",
+		}
+		if len(vendors) != len(want) {
+			t.Errorf("%s: vendors = %q, want %q", path, vendors, want)
+		}
+		for oui, vendor := range want {
+			if vendors[oui] != vendor {
+				t.Errorf("%s: vendor of %06X = %q, want %q", path, oui, vendors[oui], vendor)
+			}
+		}
+	}
+}
+
+func TestBundledOUI(t *testing.T) {
+	vendors := make(map[uint32]string)
+	if err := loadOUIFile(filepath.Join("..", "assets", "oui.txt"), vendors); err != nil {
+		t.Fatal(err)
+	}
+	if vendors[0x00077c] != "Westermo Teleindustri AB" {
+		t.Errorf("vendor of 00-07-7C = %q", vendors[0x00077c])
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Size of a port, and the space around it, in SVG user units
+const (
+	portWidth  = 40
+	portHeight = 32
+	portGap    = 8
+	portMargin = 12
+)
+
+// portMapFile is the layout of the front panel of a model, read from
+// assets/portmap/<model>.json, e.g.:
+//
+//	{"ports": [{"name": "e1", "label": "1", "row": 0, "column": 0},
+//	           {"name": "e9", "label": "SFP1", "row": 0, "column": 9, "kind": "sfp"}]}
+type portMapFile struct {
+	Ports []portMapPort `json:"ports"`
+}
+
+// portMapPort is a port of a layout, the kind is "rj45", the default,
+// or "sfp"
+type portMapPort struct {
+	Name   string `json:"name"`
+	Label  string `json:"label"`
+	Row    int    `json:"row"`
+	Column int    `json:"column"`
+	Kind   string `json:"kind"`
+}
+
+// PortView is a port drawn on the front panel
+type PortView struct {
+	Name  string
+	Label string
+	Kind  string
+	X     int
+	Y     int
+	Class string
+	Title string
+}
+
+// PortMap is the front panel of the device
+type PortMap struct {
+	Model  string
+	Width  int
+	Height int
+	Ports  []PortView
+}
+
+// modelFile matches characters not used in layout file names
+var modelFile = regexp.MustCompile(`[^a-z0-9]+`)
+
+// portMapPath returns the layout file of a model, e.g., "Foo Bar 10"
+// is foo-bar-10.json
+func portMapPath(model string) string {
+	name := strings.Trim(modelFile.ReplaceAllString(strings.ToLower(model), "-"), "-")
+	return filepath.Join(staticPath, "assets", "portmap", name+".json")
+}
+
+// portMapPaths returns the layout files of a model, from the most
+// specific, for layouts of models with revisions or variants, e.g.,
+// "Foo Bar 10 Rev 1.2" uses foo-bar-10.json unless there is a
+// foo-bar-10-rev-1-2.json.  At least two words of the model are kept.
+func portMapPaths(model string) []string {
+	words := strings.Fields(modelFile.ReplaceAllString(strings.ToLower(model), " "))
+
+	var paths []string
+	for n := len(words); n > 0 && (n >= 2 || len(words) == 1); n-- {
+		paths = append(paths, portMapPath(strings.Join(words[:n], " ")))
+	}
+	return paths
+}
+
+// isPhysical returns if an interface is a port of the device, rather
+// than a virtual interface
+func isPhysical(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name, "device"))
+	return err == nil
+}
+
+// loadPortMap reads the layout of a model, or lays out the physical
+// ports on one row when there is none
+func loadPortMap(model string, ifaces []Interface) *portMapFile {
+	for _, path := range portMapPaths(model) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		layout := &portMapFile{}
+		if err := json.Unmarshal(data, layout); err != nil {
+			log.Printf("Error parsing port map %s: %v", path, err)
+			break
+		}
+		return layout
+	}
+
+	layout := &portMapFile{}
+	column := 0
+	for _, iface := range ifaces {
+		if !isPhysical(iface.Name) {
+			continue
+		}
+		layout.Ports = append(layout.Ports, portMapPort{Name: iface.Name, Column: column})
+		column++
+	}
+
+	return layout
+}
+
+// portClass returns the colour of a port, by link state and speed
+func portClass(state string, ethernet *EthernetInfo) string {
+	if state != "UP" {
+		return "port-down"
+	}
+	if ethernet == nil {
+		return "port-up"
+	}
+
+	switch {
+	case strings.HasSuffix(ethernet.Speed, "Mb/s"):
+		return "port-slow"
+	case ethernet.Speed == "1 Gb/s":
+		return "port-gigabit"
+	case strings.HasSuffix(ethernet.Speed, "Gb/s"):
+		return "port-fast"
+	}
+	return "port-up"
+}
+
+// getPortMap returns the front panel of the device, with the state of
+// the ports
+func getPortMap(ifaces []Interface) *PortMap {
+	model := getSystemModel()
+	layout := loadPortMap(model, ifaces)
+	if len(layout.Ports) == 0 {
+		return nil
+	}
+
+	states := make(map[string]string)
+	for _, iface := range ifaces {
+		states[iface.Name] = iface.State
+	}
+
+	pm := &PortMap{Model: model}
+	for _, p := range layout.Ports {
+		view := PortView{
+			Name:  p.Name,
+			Label: p.Label,
+			Kind:  p.Kind,
+			X:     portMargin + p.Column*(portWidth+portGap),
+			Y:     portMargin + p.Row*(portHeight+portGap+12),
+		}
+		if view.Label == "" {
+			view.Label = p.Name
+		}
+
+		state, ok := states[p.Name]
+		if !ok {
+			state = "NOTPRESENT"
+		}
+
+		// Only the speed is shown, modules are not read
+		var ethernet *EthernetInfo
+		if state == "UP" {
+			ethernet, _ = getLinkInfo(p.Name)
+		}
+		view.Class = portClass(state, ethernet)
+		view.Title = fmt.Sprintf("%s: %s", p.Name, state)
+		if ethernet != nil && state == "UP" && ethernet.Speed != "Unknown" {
+			view.Title += ", " + ethernet.Speed
+			if ethernet.Duplex != "Unknown" {
+				view.Title += fmt.Sprintf(" %s duplex", strings.ToLower(ethernet.Duplex))
+			}
+		}
+
+		pm.Width = max(pm.Width, view.X+portWidth+portMargin)
+		pm.Height = max(pm.Height, view.Y+portHeight+12+portMargin)
+		pm.Ports = append(pm.Ports, view)
+	}
+
+	return pm
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPortMapPaths(t *testing.T) {
+	saved := staticPath
+	staticPath = "/usr/share/webui"
+	defer func() { staticPath = saved }()
+
+	dir := "/usr/share/webui/assets/portmap/"
+	tests := []struct {
+		model string
+		want  []string
+	}{
+		{"Foo Bar 10", []string{"foo-bar-10.json", "foo-bar.json"}},
+		{"Acme Switch 8+2", []string{"acme-switch-8-2.json", "acme-switch-8.json", "acme-switch.json"}},
+		{"Unknown", []string{"unknown.json"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		var want []string
+		for _, name := range tt.want {
+			want = append(want, dir+name)
+		}
+		if got := portMapPaths(tt.model); !reflect.DeepEqual(got, want) {
+			t.Errorf("portMapPaths(%q) = %q, want %q", tt.model, got, want)
+		}
+	}
+}
+
+func TestBundledPortMaps(t *testing.T) {
+	saved := staticPath
+	staticPath = ".."
+	defer func() { staticPath = saved }()
+
+	// Models as in the device tree of the boards
+	models := map[string]string{
+		"Bananapi BPI-R3":                              "bananapi-bpi-r3.json",
+		"FriendlyElec NanoPi R2S":                      "friendlyelec-nanopi-r2s.json",
+		"Globalscale Marvell ESPRESSOBin Board":        "globalscale-marvell-espressobin-board.json",
+		"Globalscale Marvell ESPRESSOBin Board (eMMC)": "globalscale-marvell-espressobin-board.json",
+		"Globalscale Marvell ESPRESSOBin Board V7":     "globalscale-marvell-espressobin-board.json",
+		"Raspberry Pi 4 Model B Rev 1.4":               "raspberry-pi-4-model-b.json",
+		"StarFive VisionFive 2 v1.3B":                  "starfive-visionfive-2.json",
+	}
+	for model, file := range models {
+		layout := loadPortMap(model, nil)
+		if len(layout.Ports) == 0 {
+			t.Errorf("%s: no layout, want %s", model, file)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join("..", "assets", "portmap", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no layouts: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var layout portMapFile
+		if err := json.Unmarshal(data, &layout); err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+
+		names := make(map[string]bool)
+		places := make(map[[2]int]bool)
+		for _, p := range layout.Ports {
+			place := [2]int{p.Row, p.Column}
+			if p.Name == "" || names[p.Name] || places[place] {
+				t.Errorf("%s: port %q unnamed, or named or placed twice", file, p.Name)
+			}
+			if p.Kind != "" && p.Kind != "rj45" && p.Kind != "sfp" {
+				t.Errorf("%s: port %s of unknown kind %q", file, p.Name, p.Kind)
+			}
+			names[p.Name] = true
+			places[place] = true
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// Number of routes per page of the routing table
+const ribPageSize = 50
+
+// Rule is a policy routing rule, as ip rule shows it
+type Rule struct {
+	Priority int
+	Rule     string
+	Table    string
+}
+
+// RIBInfo is a page of the routing table of an address family, with
+// the policy rules selecting the tables
+type RIBInfo struct {
+	Family string
+	Table  string
+	Query  string
+	Tables []string
+	Routes []Route
+	Rules  []Rule
+	Total  int
+	Page   int
+	Pages  int
+	Error  string
+}
+
+// Prev returns the previous page, or 0 if this is the first page
+func (r *RIBInfo) Prev() int {
+	if r.Page > 1 {
+		return r.Page - 1
+	}
+	return 0
+}
+
+// Next returns the next page, or 0 if this is the last page
+func (r *RIBInfo) Next() int {
+	if r.Page < r.Pages {
+		return r.Page + 1
+	}
+	return 0
+}
+
+// PageLink returns the query parameters for a page of the table
+func (r *RIBInfo) PageLink(page int) template.URL {
+	q := url.Values{}
+	q.Set("family", r.Family)
+	if r.Table != "" {
+		q.Set("table", r.Table)
+	}
+	if r.Query != "" {
+		q.Set("q", r.Query)
+	}
+	q.Set("page", strconv.Itoa(page))
+
+	return template.URL(q.Encode())
+}
+
+// ribNextHopJSON is a next hop of a next hop list
+type ribNextHopJSON struct {
+	OutgoingInterface string `json:"outgoing-interface"`
+	Address4          string `json:"ietf-ipv4-unicast-routing:address"`
+	Address6          string `json:"ietf-ipv6-unicast-routing:address"`
+}
+
+// ribRouteJSON is a route of the operational RIB of ietf-routing
+type ribRouteJSON struct {
+	Destination4   string           `json:"ietf-ipv4-unicast-routing:destination-prefix"`
+	Destination6   string           `json:"ietf-ipv6-unicast-routing:destination-prefix"`
+	SourceProtocol string           `json:"source-protocol"`
+	Active         *json.RawMessage `json:"active"`
+	LastUpdated    string           `json:"last-updated"`
+	NextHop        struct {
+		OutgoingInterface string `json:"outgoing-interface"`
+		NextHopAddress4   string `json:"ietf-ipv4-unicast-routing:next-hop-address"`
+		NextHopAddress6   string `json:"ietf-ipv6-unicast-routing:next-hop-address"`
+		SpecialNextHop    string `json:"special-next-hop"`
+		NextHopList       struct {
+			NextHop []ribNextHopJSON `json:"next-hop"`
+		} `json:"next-hop-list"`
+	} `json:"next-hop"`
+}
+
+// ribJSON is the operational RIBs of ietf-routing
+type ribJSON struct {
+	Routing struct {
+		Ribs struct {
+			Rib []struct {
+				Name   string `json:"name"`
+				Routes struct {
+					Route []ribRouteJSON `json:"route"`
+				} `json:"routes"`
+			} `json:"rib"`
+		} `json:"ribs"`
+	} `json:"ietf-routing:routing"`
+}
+
+// ribDestination returns a destination prefix as the kernel routes
+// are shown, i.e., default, and host routes without prefix length
+func ribDestination(destination string) string {
+	prefix, err := netip.ParsePrefix(destination)
+	if err != nil {
+		return destination
+	}
+
+	switch prefix.Bits() {
+	case 0:
+		return "default"
+	case prefix.Addr().BitLen():
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// formatAge returns the time since a route changed, e.g., 2d03h
+func formatAge(d time.Duration) string {
+	d = d.Truncate(time.Second)
+
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// getOperRIB reads the routes of an address family from the
+// operational RIB, which, unlike the kernel, has the time of the last
+// change, and the routes not selected
+func getOperRIB(ipv6 bool) ([]Route, error) {
+	data, err := getDataJSON(sr.DSOperational, routingXPath+"/ribs")
+	if err != nil {
+		return nil, err
+	}
+
+	var rib ribJSON
+	if err := json.Unmarshal(data, &rib); err != nil {
+		return nil, fmt.Errorf("failed to parse RIB: %w", err)
+	}
+
+	var routes []Route
+	for _, r := range rib.Routing.Ribs.Rib {
+		for _, rr := range r.Routes.Route {
+			destination := rr.Destination4
+			if ipv6 {
+				destination = rr.Destination6
+			}
+			if destination == "" {
+				continue
+			}
+
+			route := Route{
+				Destination: ribDestination(destination),
+				Gateway:     rr.NextHop.NextHopAddress4 + rr.NextHop.NextHopAddress6,
+				Device:      rr.NextHop.OutgoingInterface,
+				Protocol:    identityName(rr.SourceProtocol),
+				Table:       "main",
+				Type:        rr.NextHop.SpecialNextHop,
+				Inactive:    rr.Active == nil,
+			}
+			if t, err := time.Parse(time.RFC3339, rr.LastUpdated); err == nil {
+				route.Age = formatAge(time.Since(t))
+			}
+			for _, nh := range rr.NextHop.NextHopList.NextHop {
+				route.NextHops = append(route.NextHops, NextHop{
+					Gateway: nh.Address4 + nh.Address6,
+					Device:  nh.OutgoingInterface,
+				})
+			}
+
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// tableRank orders the routing tables, main first and local last
+func tableRank(table string) int {
+	switch table {
+	case "main":
+		return 0
+	case "local":
+		return 2
+	}
+	return 1
+}
+
+// sortRoutes sorts routes by table, with the default route first
+func sortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Table != b.Table {
+			if tableRank(a.Table) != tableRank(b.Table) {
+				return tableRank(a.Table) < tableRank(b.Table)
+			}
+			return a.Table < b.Table
+		}
+		if a.Destination == "default" {
+			return b.Destination != "default"
+		}
+		if b.Destination == "default" {
+			return false
+		}
+
+		return a.Destination < b.Destination
+	})
+}
+
+// getRIB returns the routes of all tables of an address family, and
+// the policy rules.  Tables of VRFs are named by the VRF.  The main
+// table is completed with the operational RIB, when available.
+func getRIB(ipv6 bool) ([]Route, []Rule, error) {
+	family := syscall.AF_INET
+	if ipv6 {
+		family = syscall.AF_INET6
+	}
+
+	links, names, err := getLinks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vrfs := make(map[string]string)
+	for _, l := range links {
+		if l.Kind == "vrf" && l.Table != 0 {
+			vrfs[tableName(l.Table)] = l.Name
+		}
+	}
+	tables := func(table uint32) string {
+		if vrf, ok := vrfs[tableName(table)]; ok {
+			return vrf
+		}
+		return tableName(table)
+	}
+
+	msgs, err := netlinkDump(syscall.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, nil, err
+	}
+	routes := parseRoutes(msgs, names)
+	for i := range routes {
+		if vrf, ok := vrfs[routes[i].Table]; ok {
+			routes[i].Table = vrf
+		}
+	}
+
+	msgs, err = netlinkDump(syscall.RTM_GETRULE, family)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules := parseRules(msgs, tables)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+
+	rib, err := getOperRIB(ipv6)
+	if err != nil && !errors.Is(err, errNoData) {
+		log.Printf("Error reading operational RIB, using kernel routes only: %v", err)
+	}
+
+	// Selected routes are in the kernel, add their age, and the
+	// routes not selected
+	age := make(map[string]string)
+	for _, r := range rib {
+		if r.Inactive {
+			routes = append(routes, r)
+		} else {
+			age[r.Destination] = r.Age
+		}
+	}
+	for i := range routes {
+		if routes[i].Table == "main" && routes[i].Age == "" {
+			routes[i].Age = age[routes[i].Destination]
+		}
+	}
+
+	sortRoutes(routes)
+
+	return routes, rules, nil
+}
+
+// matchRoute returns if any field of a route contains the query
+func matchRoute(r Route, query string) bool {
+	fields := []string{r.Destination, r.Gateway, r.Device, r.Protocol, r.Type}
+	for _, nh := range r.NextHops {
+		fields = append(fields, nh.Gateway, nh.Device)
+	}
+
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRIBInfo returns the page of the routing table selected by the
+// query parameters
+func getRIBInfo(q url.Values) *RIBInfo {
+	info := &RIBInfo{
+		Family: q.Get("family"),
+		Table:  q.Get("table"),
+		Query:  strings.TrimSpace(q.Get("q")),
+	}
+	if info.Family != "6" {
+		info.Family = "4"
+	}
+
+	routes, rules, err := getRIB(info.Family == "6")
+	if err != nil {
+		log.Printf("Error getting routing table: %v", err)
+		info.Error = "Failed to get the routing table"
+		return info
+	}
+	info.Rules = rules
+
+	seen := make(map[string]bool)
+	for _, r := range routes {
+		if !seen[r.Table] {
+			seen[r.Table] = true
+			info.Tables = append(info.Tables, r.Table)
+		}
+	}
+
+	query := strings.ToLower(info.Query)
+	var matched []Route
+	for _, r := range routes {
+		if info.Table != "" && r.Table != info.Table {
+			continue
+		}
+		if query != "" && !matchRoute(r, query) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	info.Total = len(matched)
+	info.Pages = max(1, (info.Total+ribPageSize-1)/ribPageSize)
+	info.Page, _ = strconv.Atoi(q.Get("page"))
+	info.Page = min(max(info.Page, 1), info.Pages)
+
+	start := (info.Page - 1) * ribPageSize
+	info.Routes = matched[start:min(start+ribPageSize, info.Total)]
+
+	return info
+}
+
+// ribHandler returns a page of the routing table
+func ribHandler(w http.ResponseWriter, r *http.Request) {
+	renderFragment(w, "rib", getRIBInfo(r.URL.Query()))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+const routingXPath = "/ietf-routing:routing"
+
+// staticProtocol is the type of the one static control plane
+// protocol, unless already configured as a derived identity
+const staticProtocol = "ietf-routing:static"
+
+// StaticRoute is a configured static route, with a next hop address,
+// an outgoing interface, or both
+type StaticRoute struct {
+	Destination string
+	NextHop     string
+	Interface   string
+	Preference  string
+	prefix      netip.Prefix
+}
+
+// RoutesInfo holds data for the routes page
+type RoutesInfo struct {
+	Static     []StaticRoute
+	New        StaticRoute
+	Errors     map[string]string
+	Interfaces []string
+	RIB        *RIBInfo
+	Message    string
+	Error      string
+}
+
+// staticRouteJSON is a route of ietf-ipv4-unicast-routing, or
+// ietf-ipv6-unicast-routing
+type staticRouteJSON struct {
+	DestinationPrefix string `json:"destination-prefix"`
+	NextHop           struct {
+		NextHopAddress    string `json:"next-hop-address"`
+		OutgoingInterface string `json:"outgoing-interface"`
+	} `json:"next-hop"`
+	RoutePreference json.Number `json:"route-preference"`
+}
+
+// routingJSON is the static routes of the routing model
+type routingJSON struct {
+	Routing struct {
+		ControlPlaneProtocols struct {
+			ControlPlaneProtocol []struct {
+				Type         string `json:"type"`
+				Name         string `json:"name"`
+				StaticRoutes struct {
+					IPv4 struct {
+						Route []staticRouteJSON `json:"route"`
+					} `json:"ietf-ipv4-unicast-routing:ipv4"`
+					IPv6 struct {
+						Route []staticRouteJSON `json:"route"`
+					} `json:"ietf-ipv6-unicast-routing:ipv6"`
+				} `json:"static-routes"`
+			} `json:"control-plane-protocol"`
+		} `json:"control-plane-protocols"`
+	} `json:"ietf-routing:routing"`
+}
+
+// getStaticRoutes reads the static routes from the running datastore,
+// and the type of their control plane protocol
+func getStaticRoutes() ([]StaticRoute, string, error) {
+	data, err := getDataJSON(sr.DSRunning, routingXPath)
+	if errors.Is(err, errNoData) {
+		return nil, staticProtocol, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var cfg routingJSON
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, "", fmt.Errorf("failed to parse routing configuration: %w", err)
+	}
+
+	var routes []StaticRoute
+	protocol := staticProtocol
+	for _, p := range cfg.Routing.ControlPlaneProtocols.ControlPlaneProtocol {
+		if identityName(p.Type) != "static" || p.Name != "default" {
+			continue
+		}
+		protocol = p.Type
+
+		for _, r := range append(p.StaticRoutes.IPv4.Route, p.StaticRoutes.IPv6.Route...) {
+			route := StaticRoute{
+				Destination: r.DestinationPrefix,
+				NextHop:     r.NextHop.NextHopAddress,
+				Interface:   r.NextHop.OutgoingInterface,
+				Preference:  r.RoutePreference.String(),
+			}
+			route.prefix, _ = netip.ParsePrefix(route.Destination)
+			routes = append(routes, route)
+		}
+	}
+
+	// IPv4 first, then by prefix
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i].prefix, routes[j].prefix
+		if a.Addr().Is4() != b.Addr().Is4() {
+			return a.Addr().Is4()
+		}
+		if a.Addr() != b.Addr() {
+			return a.Addr().Less(b.Addr())
+		}
+		return a.Bits() < b.Bits()
+	})
+
+	return routes, protocol, nil
+}
+
+// staticRoutePath returns the xpath of a static route
+func staticRoutePath(protocol string, prefix netip.Prefix) string {
+	family := "ietf-ipv4-unicast-routing:ipv4"
+	if prefix.Addr().Is6() {
+		family = "ietf-ipv6-unicast-routing:ipv6"
+	}
+	return fmt.Sprintf("%s/control-plane-protocols/control-plane-protocol[type='%s'][name='default']/static-routes/%s/route[destination-prefix='%s']",
+		routingXPath, protocol, family, prefix)
+}
+
+// parseRouteForm reads and validates a static route from a form
+func parseRouteForm(r *http.Request, interfaces []string) (StaticRoute, map[string]string) {
+	route := StaticRoute{
+		Destination: strings.TrimSpace(r.FormValue("destination")),
+		NextHop:     strings.TrimSpace(r.FormValue("next-hop")),
+		Interface:   r.FormValue("interface"),
+		Preference:  strings.TrimSpace(r.FormValue("preference")),
+	}
+	errs := make(map[string]string)
+
+	// A default route is often written as such
+	switch route.Destination {
+	case "default":
+		route.Destination = "0.0.0.0/0"
+	case "default6":
+		route.Destination = "::/0"
+	}
+
+	prefix, err := netip.ParsePrefix(route.Destination)
+	if err != nil {
+		errs["destination"] = "Must be a prefix, e.g., 10.0.0.0/8 or 2001:db8::/32"
+	} else if prefix != prefix.Masked() {
+		errs["destination"] = fmt.Sprintf("Host bits are set, did you mean %s?", prefix.Masked())
+	}
+	route.prefix = prefix
+
+	if route.NextHop == "" && route.Interface == "" {
+		errs["next-hop"] = "A next hop address, or an interface, is required"
+	}
+	if route.NextHop != "" {
+		addr, err := netip.ParseAddr(route.NextHop)
+		switch {
+		case err != nil:
+			errs["next-hop"] = "Must be an IP address"
+		case errs["destination"] == "" && addr.Is4() != prefix.Addr().Is4():
+			errs["next-hop"] = "Must be of the same family as the destination"
+		case addr.IsLinkLocalUnicast() && route.Interface == "":
+			// Link-local addresses are only unique on a link
+			errs["interface"] = "Required for a link-local next hop"
+		}
+	}
+	if route.Interface != "" && !slices.Contains(interfaces, route.Interface) {
+		errs["interface"] = "No such interface"
+	}
+
+	validateNumber(errs, "preference", route.Preference, 1, 255)
+
+	return route, errs
+}
+
+// ProtocolClass returns the badge colour of the source of a route,
+// static routes stand out from the ones learned
+func (r Route) ProtocolClass() string {
+	switch r.Protocol {
+	case "static":
+		return "bg-primary"
+	case "kernel", "boot":
+		return "bg-secondary"
+	case "dhcp", "ra", "redirect":
+		return "bg-info text-dark"
+	}
+	return "bg-warning text-dark"
+}
+
+// interfaceNames returns the names of all interfaces, for selecting
+// the outgoing interface of a route
+func interfaceNames() []string {
+	var names []string
+
+	ifaces, _, err := getInterfaces()
+	if err != nil {
+		log.Printf("Error getting interfaces: %v", err)
+		return nil
+	}
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+
+	return names
+}
+
+// renderRoutes renders the routes page, as currently configured, with
+// the result of a change, and the first page of the IPv4 routing table
+func renderRoutes(w http.ResponseWriter, r *http.Request, info *RoutesInfo) {
+	var err error
+
+	info.Static, _, err = getStaticRoutes()
+	if err != nil {
+		log.Printf("Error reading static routes: %v", err)
+		if info.Error == "" {
+			info.Error = "Failed to read the static routes"
+		}
+	}
+	if info.Interfaces == nil {
+		info.Interfaces = interfaceNames()
+	}
+
+	info.RIB = getRIBInfo(url.Values{})
+
+	renderPage(w, r, "routes", info)
+}
+
+// routesHandler shows the static routes, and the routing table
+func routesHandler(w http.ResponseWriter, r *http.Request) {
+	renderRoutes(w, r, &RoutesInfo{})
+}
+
+// saveRouteHandler adds, or changes, a static route
+func saveRouteHandler(w http.ResponseWriter, r *http.Request) {
+	interfaces := interfaceNames()
+
+	route, errs := parseRouteForm(r, interfaces)
+	if len(errs) > 0 {
+		// Show the form again, as entered, with the problems
+		renderRoutes(w, r, &RoutesInfo{New: route, Errors: errs, Interfaces: interfaces})
+		return
+	}
+
+	_, protocol, err := getStaticRoutes()
+	if err != nil {
+		log.Printf("Error reading static routes: %v", err)
+		renderRoutes(w, r, &RoutesInfo{New: route, Interfaces: interfaces, Error: "Failed to read the static routes"})
+		return
+	}
+
+	err = editConfig(func(sess *sr.Session) error {
+		// Leaves of the next hop not given are removed
+		leaves := map[string]string{
+			"next-hop/next-hop-address":   route.NextHop,
+			"next-hop/outgoing-interface": route.Interface,
+			"route-preference":            route.Preference,
+		}
+		return setItems(sess, staticRoutePath(protocol, route.prefix), leaves)
+	})
+	if err != nil {
+		log.Printf("Error saving static route %s: %v", route.prefix, err)
+		renderRoutes(w, r, &RoutesInfo{New: route, Interfaces: interfaces, Error: fmt.Sprintf("Failed to save route %s: %v", route.prefix, err)})
+		return
+	}
+
+	log.Printf("Static route %s applied by %s", route.prefix, getUsername(r))
+	renderRoutes(w, r, &RoutesInfo{Message: fmt.Sprintf("Route %s applied", route.prefix)})
+}
+
+// deleteRouteHandler removes a static route
+func deleteRouteHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, err := netip.ParsePrefix(r.FormValue("destination"))
+	if err != nil {
+		http.Error(w, "Invalid destination", http.StatusBadRequest)
+		return
+	}
+
+	_, protocol, err := getStaticRoutes()
+	if err != nil {
+		log.Printf("Error reading static routes: %v", err)
+		renderRoutes(w, r, &RoutesInfo{Error: "Failed to read the static routes"})
+		return
+	}
+
+	err = editConfig(func(sess *sr.Session) error {
+		return sess.DeleteItem(staticRoutePath(protocol, prefix), sr.EditDefault)
+	})
+	if err != nil {
+		log.Printf("Error deleting static route %s: %v", prefix, err)
+		renderRoutes(w, r, &RoutesInfo{Error: fmt.Sprintf("Failed to delete route %s: %v", prefix, err)})
+		return
+	}
+
+	log.Printf("Static route %s deleted by %s", prefix, getUsername(r))
+	renderRoutes(w, r, &RoutesInfo{Message: fmt.Sprintf("Route %s deleted", prefix)})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRouteForm(t *testing.T) {
+	interfaces := []string{"e1", "e2", "br0"}
+
+	tests := []struct {
+		name string
+		form url.Values
+		errs map[string]string
+	}{
+		{
+			name: "next hop",
+			form: url.Values{"destination": {"10.0.0.0/8"}, "next-hop": {"192.168.1.1"}},
+			errs: map[string]string{},
+		},
+		{
+			name: "interface",
+			form: url.Values{"destination": {"10.0.0.0/8"}, "interface": {"br0"}},
+			errs: map[string]string{},
+		},
+		{
+			name: "next hop on an interface",
+			form: url.Values{"destination": {"10.0.0.0/8"}, "next-hop": {"192.168.1.1"}, "interface": {"e1"}},
+			errs: map[string]string{},
+		},
+		{
+			name: "link-local next hop on an interface",
+			form: url.Values{"destination": {"default6"}, "next-hop": {"fe80::1"}, "interface": {"e1"}},
+			errs: map[string]string{},
+		},
+		{
+			name: "link-local next hop",
+			form: url.Values{"destination": {"::/0"}, "next-hop": {"fe80::1"}},
+			errs: map[string]string{"interface": "Required for a link-local next hop"},
+		},
+		{
+			name: "neither",
+			form: url.Values{"destination": {"10.0.0.0/8"}},
+			errs: map[string]string{"next-hop": "A next hop address, or an interface, is required"},
+		},
+		{
+			name: "other family",
+			form: url.Values{"destination": {"10.0.0.0/8"}, "next-hop": {"2001:db8::1"}, "interface": {"e1"}},
+			errs: map[string]string{"next-hop": "Must be of the same family as the destination"},
+		},
+		{
+			name: "unknown interface",
+			form: url.Values{"destination": {"::/0"}, "next-hop": {"fe80::1"}, "interface": {"e9"}},
+			errs: map[string]string{"interface": "No such interface"},
+		},
+		{
+			name: "host bits",
+			form: url.Values{"destination": {"10.1.0.0/8"}, "interface": {"e1"}},
+			errs: map[string]string{"destination": "Host bits are set, did you mean 10.0.0.0/8?"},
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/routes", strings.NewReader(tt.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		_, errs := parseRouteForm(r, interfaces)
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s: errors %v, want %v", tt.name, errs, tt.errs)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Job is a firmware upgrade and/or reboot scheduled for a maintenance
+// window.  Uploaded bundles are staged with the job list, in statePath,
+// until the job runs, to survive a reboot before it.
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	At         time.Time       `json:"at"`
+	Created    time.Time       `json:"created"`
+	User       string          `json:"user"`
+	RemoteAddr string          `json:"remote_addr"`
+	Source     *FirmwareSource `json:"source,omitempty"`
+	ConfigPath string          `json:"config_path,omitempty"`
+	Version    string          `json:"version,omitempty"`
+	Reboot     bool            `json:"reboot,omitempty"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Finished   time.Time       `json:"finished,omitempty"`
+}
+
+// ScheduleInfo holds data for the scheduled jobs page
+type ScheduleInfo struct {
+	Jobs    []Job
+	Now     time.Time
+	Message string
+	Error   string
+}
+
+// Job kinds
+const (
+	jobUpgrade = "upgrade"
+	jobReboot  = "reboot"
+)
+
+// Job states
+const (
+	jobPending  = "pending"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+const (
+	// How often the scheduler looks for jobs to run
+	schedulerInterval = 15 * time.Second
+	// How late a job may start, e.g., after a power outage or while
+	// waiting for another operation to complete
+	jobGracePeriod = time.Hour
+	// Number of finished jobs kept for reference
+	maxFinishedJobs = 20
+	// Format of <input type="datetime-local">
+	datetimeLocal = "2006-01-02T15:04"
+)
+
+var jobsMutex sync.Mutex
+
+// jobsPath returns the path to the persistent job list
+func jobsPath() string {
+	return filepath.Join(statePath, "jobs.json")
+}
+
+// stagedDir returns the directory of the staged bundles and
+// configurations of scheduled upgrades
+func stagedDir() string {
+	return filepath.Join(statePath, "scheduled")
+}
+
+// stagedPath returns where the bundle of a scheduled upgrade is kept
+func stagedPath(id, ext string) string {
+	return filepath.Join(stagedDir(), id+ext)
+}
+
+// missingStaged returns what a pending job needs, but is no longer
+// staged, if anything
+func missingStaged(job *Job) string {
+	if job.Source != nil && job.Source.Type == sourceUpload {
+		if _, err := os.Stat(job.Source.Path); err != nil {
+			return "Staged firmware bundle missing"
+		}
+	}
+	if job.ConfigPath != "" {
+		if _, err := os.Stat(job.ConfigPath); err != nil {
+			return "Staged configuration missing"
+		}
+	}
+	return ""
+}
+
+// loadJobs reads the job list
+func loadJobs() ([]Job, error) {
+	var jobs []Job
+
+	data, err := os.ReadFile(jobsPath())
+	if os.IsNotExist(err) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// saveJobs writes the job list atomically, dropping the oldest of the
+// finished jobs
+func saveJobs(jobs []Job) error {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].At.Before(jobs[j].At)
+	})
+
+	finished := 0
+	for _, job := range jobs {
+		if job.Status != jobPending && job.Status != jobRunning {
+			finished++
+		}
+	}
+
+	kept := jobs[:0]
+	for _, job := range jobs {
+		if finished > maxFinishedJobs && job.Status != jobPending && job.Status != jobRunning {
+			finished--
+			continue
+		}
+		kept = append(kept, job)
+	}
+
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := jobsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, jobsPath())
+}
+
+// updateJobs loads the job list, calls fn to modify it, and saves it
+func updateJobs(fn func(jobs []Job) ([]Job, error)) error {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+
+	if jobs, err = fn(jobs); err != nil {
+		return err
+	}
+
+	return saveJobs(jobs)
+}
+
+// setJobStatus updates the status of a job
+func setJobStatus(id, status, message string) {
+	err := updateJobs(func(jobs []Job) ([]Job, error) {
+		for i := range jobs {
+			if jobs[i].ID != id {
+				continue
+			}
+
+			jobs[i].Status = status
+			jobs[i].Error = message
+			if status != jobPending && status != jobRunning {
+				jobs[i].Finished = time.Now()
+			}
+		}
+		return jobs, nil
+	})
+	if err != nil {
+		log.Printf("Error saving job %s: %v", id, err)
+	}
+}
+
+// claimJob marks a pending job as running
+func claimJob(id string) error {
+	return updateJobs(func(jobs []Job) ([]Job, error) {
+		for i := range jobs {
+			if jobs[i].ID != id {
+				continue
+			}
+			if jobs[i].Status != jobPending {
+				return nil, fmt.Errorf("job is %s", jobs[i].Status)
+			}
+
+			jobs[i].Status = jobRunning
+			return jobs, nil
+		}
+		return nil, fmt.Errorf("no such job")
+	})
+}
+
+// removeStaged removes the staged bundle and configuration of a job
+func removeStaged(job *Job) {
+	if job.Source != nil && job.Source.Type == sourceUpload {
+		os.Remove(job.Source.Path)
+	}
+	if job.ConfigPath != "" {
+		os.Remove(job.ConfigPath)
+	}
+}
+
+// addJob adds a new pending job
+func addJob(job *Job) error {
+	id := make([]byte, 8)
+	io.ReadFull(rand.Reader, id)
+	job.ID = hex.EncodeToString(id)
+	job.Created = time.Now()
+	job.Status = jobPending
+
+	return updateJobs(func(jobs []Job) ([]Job, error) {
+		return append(jobs, *job), nil
+	})
+}
+
+// parseJobTime parses the time of a job, in the local time of the
+// device, and checks that it is in the future
+func parseJobTime(value string) (time.Time, error) {
+	at, err := time.ParseInLocation(datetimeLocal, value, time.Local)
+	if err != nil {
+		return at, fmt.Errorf("invalid date and time")
+	}
+	if at.Before(time.Now()) {
+		return at, fmt.Errorf("%s is in the past", at.Format("2006-01-02 15:04"))
+	}
+
+	return at, nil
+}
+
+// startScheduler starts the scheduler, jobs interrupted by a restart
+// of the webui, or with their staged files gone, are marked as failed
+func startScheduler() {
+	err := updateJobs(func(jobs []Job) ([]Job, error) {
+		for i := range jobs {
+			switch jobs[i].Status {
+			case jobRunning:
+				jobs[i].Status = jobFailed
+				jobs[i].Error = "Interrupted by restart"
+				jobs[i].Finished = time.Now()
+			case jobPending:
+				if missing := missingStaged(&jobs[i]); missing != "" {
+					log.Printf("Scheduled %s %s: %s", jobs[i].Kind, jobs[i].ID, missing)
+					jobs[i].Status = jobFailed
+					jobs[i].Error = missing
+					jobs[i].Finished = time.Now()
+					removeStaged(&jobs[i])
+				}
+			}
+		}
+		return jobs, nil
+	})
+	if err != nil {
+		log.Printf("Error reading scheduled jobs: %v", err)
+	}
+
+	go func() {
+		for {
+			runDueJobs()
+			time.Sleep(schedulerInterval)
+		}
+	}()
+}
+
+// runDueJobs runs pending jobs that are due, in order
+func runDueJobs() {
+	jobsMutex.Lock()
+	jobs, err := loadJobs()
+	jobsMutex.Unlock()
+	if err != nil {
+		log.Printf("Error reading scheduled jobs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Status != jobPending || job.At.After(now) {
+			continue
+		}
+
+		if now.Sub(job.At) > jobGracePeriod {
+			log.Printf("Scheduled %s %s missed its maintenance window at %s", job.Kind, job.ID, job.At)
+			setJobStatus(job.ID, jobFailed, "Missed maintenance window")
+			removeStaged(job)
+			continue
+		}
+
+		runJob(job)
+	}
+}
+
+// runJob runs a job that is due.  If another operation is in progress
+// the job is left pending, to be retried within the grace period.
+func runJob(job *Job) {
+	kind := "Scheduled firmware upgrade"
+	if job.Kind == jobReboot {
+		kind = "Scheduled reboot"
+	}
+
+	op, err := beginOperation(kind, job.User)
+	if err != nil {
+		log.Printf("Postponing scheduled %s %s: %v", job.Kind, job.ID, err)
+		return
+	}
+
+	// The job may have been canceled since the job list was read
+	if err := claimJob(job.ID); err != nil {
+		log.Printf("Not running scheduled %s %s: %v", job.Kind, job.ID, err)
+		op.End()
+		return
+	}
+
+	log.Printf("Running scheduled %s %s, scheduled by %s for %s", job.Kind, job.ID, job.User, job.At)
+
+	if job.Kind == jobReboot {
+		setJobStatus(job.ID, jobDone, "")
+		op.SetCancelable(false)
+		rebootSystem(op)
+		return
+	}
+
+	status := runScheduledUpgrade(op, job)
+	op.End()
+	removeStaged(job)
+
+	if status.Canceled {
+		setJobStatus(job.ID, jobCanceled, status.Message)
+		return
+	}
+	if status.Status != "completed" {
+		message := status.Error
+		if message == "" {
+			message = status.Message
+		}
+		setJobStatus(job.ID, jobFailed, message)
+		return
+	}
+
+	setJobStatus(job.ID, jobDone, "")
+	if !job.Reboot {
+		return
+	}
+
+	op, err = beginOperation("Scheduled reboot", job.User)
+	if err != nil {
+		log.Printf("Cannot reboot after scheduled upgrade %s: %v", job.ID, err)
+		setJobStatus(job.ID, jobFailed, fmt.Sprintf("Upgrade completed, but reboot failed: %v", err))
+		return
+	}
+	op.SetCancelable(false)
+	rebootSystem(op)
+}
+
+// runScheduledUpgrade installs the bundle of a job and returns the
+// final upgrade status.  Staged bundles are verified against the
+// checksum from when the job was scheduled.
+func runScheduledUpgrade(op *Operation, job *Job) UpgradeStatus {
+	src := *job.Source
+
+	currentUpgradeMutex.Lock()
+	currentUpgrade = UpgradeStatus{
+		Status:   "installing",
+		Progress: 0,
+		Message:  "Starting scheduled installation...",
+	}
+	currentUpgradeMutex.Unlock()
+
+	rec := newUpgradeRecord(job.User, job.RemoteAddr, src)
+
+	var err error
+	if err = validateFirmwareSource(src); err == nil && src.SHA256 != "" {
+		err = checkStagedBundle(src)
+	}
+	if err != nil {
+		log.Printf("Scheduled upgrade %s: invalid firmware %s: %v", job.ID, src, err)
+		updateUpgradeStatus("error", 0, fmt.Sprintf("Invalid firmware: %v", err))
+
+		currentUpgradeMutex.Lock()
+		status := currentUpgrade
+		currentUpgradeMutex.Unlock()
+
+		finishUpgradeRecord(rec, status)
+		return status
+	}
+
+	startUpgradeProcess(op, src, job.ConfigPath, rec)
+
+	currentUpgradeMutex.Lock()
+	defer currentUpgradeMutex.Unlock()
+
+	return currentUpgrade
+}
+
+// checkStagedBundle verifies that a staged bundle is unchanged
+func checkStagedBundle(src FirmwareSource) error {
+	f, err := os.Open(src.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != src.SHA256 {
+		return fmt.Errorf("checksum mismatch, bundle modified since it was scheduled")
+	}
+
+	return nil
+}
+
+// scheduleHandler handles the scheduled jobs page
+func scheduleHandler(w http.ResponseWriter, r *http.Request) {
+	renderSchedule(w, r, "", "")
+}
+
+// renderSchedule renders the scheduled jobs page with a message
+func renderSchedule(w http.ResponseWriter, r *http.Request, message, errMsg string) {
+	jobsMutex.Lock()
+	jobs, err := loadJobs()
+	jobsMutex.Unlock()
+	if err != nil {
+		log.Printf("Error reading scheduled jobs: %v", err)
+		errMsg = "Failed to read scheduled jobs"
+	}
+
+	// Most recent first
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].At.After(jobs[j].At)
+	})
+
+	renderPage(w, r, "schedule", &ScheduleInfo{
+		Jobs:    jobs,
+		Now:     time.Now(),
+		Message: message,
+		Error:   errMsg,
+	})
+}
+
+// scheduleUpgradeHandler schedules a firmware upgrade, and optionally
+// a reboot, from the upgrade page.  The bundle is verified now, and
+// uploaded bundles are staged until the job runs.
+func scheduleUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := os.MkdirAll(stagedDir(), 0700); err != nil {
+		log.Printf("Error creating staging directory: %v", err)
+		http.Error(w, "Failed to prepare for upload", http.StatusInternalServerError)
+		return
+	}
+
+	if err := r.ParseMultipartForm(500 << 20); err != nil {
+		log.Printf("Error parsing form: %v", err)
+		http.Error(w, "Failed to parse upload form", http.StatusBadRequest)
+		return
+	}
+
+	at, err := parseJobTime(r.FormValue("at"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Staged files are named after a temporary ID, the job ID is not
+	// known until the job is added
+	id := make([]byte, 8)
+	io.ReadFull(rand.Reader, id)
+	stage := hex.EncodeToString(id)
+
+	src, ok := receiveFirmware(w, r, stagedPath(stage, ".pkg"))
+	if !ok {
+		os.Remove(stagedPath(stage, ".pkg"))
+		return
+	}
+
+	job := &Job{
+		Kind:       jobUpgrade,
+		At:         at,
+		User:       getUsername(r),
+		RemoteAddr: remoteAddr(r),
+		Source:     &src,
+		Reboot:     r.FormValue("reboot") != "",
+	}
+
+	job.ConfigPath, ok = receiveConfig(w, r, stagedPath(stage, ".cfg"))
+	if !ok {
+		removeStaged(job)
+		return
+	}
+
+	if _, err := exec.LookPath("rauc"); err == nil {
+		info, err := inspectBundle(src.Path)
+		if err == nil {
+			err = checkBundle(info)
+		}
+		if err != nil {
+			log.Printf("Refusing to schedule %s: %v", src, err)
+			removeStaged(job)
+			http.Error(w, fmt.Sprintf("Invalid firmware package: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+		job.Version = info.Version
+	}
+
+	if err := addJob(job); err != nil {
+		log.Printf("Error saving scheduled upgrade: %v", err)
+		removeStaged(job)
+		http.Error(w, "Failed to save scheduled upgrade", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Upgrade to %s scheduled for %s by user: %s", src, at, job.User)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// scheduleRebootHandler schedules a reboot
+func scheduleRebootHandler(w http.ResponseWriter, r *http.Request) {
+	at, err := parseJobTime(r.FormValue("at"))
+	if err != nil {
+		renderSchedule(w, r, "", fmt.Sprintf("Invalid schedule: %v", err))
+		return
+	}
+
+	job := &Job{
+		Kind:       jobReboot,
+		At:         at,
+		User:       getUsername(r),
+		RemoteAddr: remoteAddr(r),
+	}
+	if err := addJob(job); err != nil {
+		log.Printf("Error saving scheduled reboot: %v", err)
+		renderSchedule(w, r, "", "Failed to save scheduled reboot")
+		return
+	}
+
+	log.Printf("Reboot scheduled for %s by user: %s", at, job.User)
+	renderSchedule(w, r, fmt.Sprintf("Reboot scheduled for %s.", at.Format("2006-01-02 15:04")), "")
+}
+
+// cancelJobHandler cancels a pending job
+func cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var canceled *Job
+	err := updateJobs(func(jobs []Job) ([]Job, error) {
+		for i := range jobs {
+			if jobs[i].ID != id {
+				continue
+			}
+			if jobs[i].Status != jobPending {
+				return nil, fmt.Errorf("job is %s", jobs[i].Status)
+			}
+
+			jobs[i].Status = jobCanceled
+			jobs[i].Error = "Canceled by " + getUsername(r)
+			jobs[i].Finished = time.Now()
+			job := jobs[i]
+			canceled = &job
+			return jobs, nil
+		}
+		return nil, fmt.Errorf("no such job")
+	})
+	if err != nil {
+		log.Printf("Error canceling job %s: %v", id, err)
+		renderSchedule(w, r, "", fmt.Sprintf("Cannot cancel job: %v", err))
+		return
+	}
+
+	removeStaged(canceled)
+	log.Printf("Scheduled %s %s canceled by user: %s", canceled.Kind, id, getUsername(r))
+	renderSchedule(w, r, fmt.Sprintf("Scheduled %s canceled.", canceled.Kind), "")
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogFilter selects log lines, the exported fields are as given in the
+// query parameters to be able to show them in the search form again
+type LogFilter struct {
+	Query    string
+	Regex    bool
+	From     string
+	To       string
+	Severity string
+	Tag      string
+
+	re       *regexp.Regexp
+	from     time.Time
+	to       time.Time
+	severity int
+	invalid  bool
+}
+
+// LogSegment is part of a log line, matching the query or not
+type LogSegment struct {
+	Text  string
+	Match bool
+}
+
+// LogMatch is a log line matching a filter
+type LogMatch struct {
+	Line     int
+	Text     string
+	Segments []LogSegment
+}
+
+// LogResult is a page of log lines matching a filter
+type LogResult struct {
+	Matches []LogMatch
+	Total   int
+	Unknown int
+	Page    int
+	Pages   int
+}
+
+const (
+	// Number of matching lines per page
+	logPageSize = 500
+	// Longest regular expression accepted
+	maxQueryLength = 256
+)
+
+// parseLogFilter parses the filter query parameters of the log page.
+// An invalid filter selects nothing, but keeps the parameters for the
+// search form.
+func parseLogFilter(q url.Values) (*LogFilter, error) {
+	f := &LogFilter{
+		Query:    q.Get("q"),
+		Regex:    q.Get("regex") != "",
+		From:     q.Get("from"),
+		To:       q.Get("to"),
+		Severity: q.Get("severity"),
+		Tag:      strings.TrimSpace(q.Get("tag")),
+		severity: -1,
+	}
+
+	if err := f.compile(); err != nil {
+		f.invalid = true
+		return f, err
+	}
+
+	return f, nil
+}
+
+// compile checks the filter parameters and prepares for matching
+func (f *LogFilter) compile() error {
+	if len(f.Query) > maxQueryLength {
+		return fmt.Errorf("search query too long")
+	}
+
+	if f.Query != "" {
+		expr := regexp.QuoteMeta(f.Query)
+		if f.Regex {
+			expr = f.Query
+		}
+
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: %v", err)
+		}
+		f.re = re
+	}
+
+	var err error
+	if f.From != "" {
+		if f.from, err = time.ParseInLocation(datetimeLocal, f.From, time.Local); err != nil {
+			return fmt.Errorf("invalid start time")
+		}
+	}
+	if f.To != "" {
+		if f.to, err = time.ParseInLocation(datetimeLocal, f.To, time.Local); err != nil {
+			return fmt.Errorf("invalid end time")
+		}
+		// Include the whole minute
+		f.to = f.to.Add(time.Minute - time.Nanosecond)
+	}
+
+	if f.Severity != "" {
+		if f.severity = parseSeverity(f.Severity); f.severity < 0 {
+			return fmt.Errorf("invalid severity")
+		}
+	}
+
+	return nil
+}
+
+// Active returns true if the filter selects anything at all
+func (f *LogFilter) Active() bool {
+	if f.invalid {
+		return false
+	}
+	return f.re != nil || !f.from.IsZero() || !f.to.IsZero() || f.severity >= 0 || f.Tag != ""
+}
+
+// Encode returns the filter as query parameters
+func (f *LogFilter) Encode() string {
+	q := url.Values{}
+	if f.Query != "" {
+		q.Set("q", f.Query)
+	}
+	if f.Regex {
+		q.Set("regex", "on")
+	}
+	if f.From != "" {
+		q.Set("from", f.From)
+	}
+	if f.To != "" {
+		q.Set("to", f.To)
+	}
+	if f.Severity != "" {
+		q.Set("severity", f.Severity)
+	}
+	if f.Tag != "" {
+		q.Set("tag", f.Tag)
+	}
+
+	return q.Encode()
+}
+
+// Link returns the filter as query parameters for links on the page
+func (f *LogFilter) Link() template.URL {
+	return template.URL(f.Encode())
+}
+
+// PageLink returns the query parameters for a page of the result
+func (f *LogFilter) PageLink(page int) template.URL {
+	q := f.Encode()
+	if q != "" {
+		q += "&"
+	}
+	return template.URL(q + "page=" + strconv.Itoa(page))
+}
+
+// TagLink returns the filter, limited to a program, as query
+// parameters for links on the page
+func (f *LogFilter) TagLink(app string) template.URL {
+	tagged := *f
+	tagged.Tag = app
+	return tagged.Link()
+}
+
+// Severities returns the severity names, for the search form
+func (f *LogFilter) Severities() []string {
+	return severityNames
+}
+
+// Match returns true if the line matches the filter.  Lines that are
+// not in syslog format only match filters on the text, and severity.
+func (f *LogFilter) Match(line string, now time.Time) bool {
+	ok, _ := f.match(line, now)
+	return ok
+}
+
+// match returns true if the line matches the filter, and if it does
+// so for a severity filter only by being of unknown severity.  Lines
+// logged without their priority may be of any severity, so they are
+// not left out by a severity filter.
+func (f *LogFilter) match(line string, now time.Time) (ok, unknown bool) {
+	if f.re != nil && !f.re.MatchString(line) {
+		return false, false
+	}
+
+	if f.from.IsZero() && f.to.IsZero() && f.severity < 0 && f.Tag == "" {
+		return true, false
+	}
+
+	entry, ok := parseSyslogLine(line, now)
+	if !ok {
+		if !f.from.IsZero() || !f.to.IsZero() || f.Tag != "" {
+			return false, false
+		}
+		return true, true
+	}
+
+	if !f.from.IsZero() && entry.Time.Before(f.from) {
+		return false, false
+	}
+	if !f.to.IsZero() && entry.Time.After(f.to) {
+		return false, false
+	}
+	if f.severity >= 0 && entry.Severity > f.severity {
+		return false, false
+	}
+	if f.Tag != "" && !strings.EqualFold(entry.App, f.Tag) {
+		return false, false
+	}
+
+	return true, f.severity >= 0 && entry.Severity < 0
+}
+
+// hasPriorities returns true if the lines at the end of a log carry
+// their priority.  Infix logs to files in the BSD format by default,
+// without it, so such logs cannot be filtered by severity, unless
+// logged with log-format rfc5424.
+func hasPriorities(f logFile) bool {
+	page, err := readLogPage(f, -1, -1)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now()
+	for _, line := range strings.Split(page.Content, "\n") {
+		if entry, ok := parseSyslogLine(line, now); ok && entry.Severity >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Highlight splits a line into segments matching the query, or not
+func (f *LogFilter) Highlight(line string) []LogSegment {
+	if f.re == nil {
+		return []LogSegment{{Text: line}}
+	}
+
+	var segments []LogSegment
+	pos := 0
+	for _, loc := range f.re.FindAllStringIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if loc[0] > pos {
+			segments = append(segments, LogSegment{Text: line[pos:loc[0]]})
+		}
+		segments = append(segments, LogSegment{Text: line[loc[0]:loc[1]], Match: true})
+		pos = loc[1]
+	}
+	if pos < len(line) {
+		segments = append(segments, LogSegment{Text: line[pos:]})
+	}
+
+	return segments
+}
+
+// searchLogFile returns a page of the lines in a log file matching the
+// filter, pages are numbered from 1, page 0 is the last page
+func searchLogFile(file logFile, filter *LogFilter, page int) (*LogResult, error) {
+	result := &LogResult{}
+	first := (page - 1) * logPageSize
+	now := time.Now()
+
+	stream, err := file.Stream()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	for lineno := 1; ; lineno++ {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		ok, unknown := filter.match(line, now)
+		if !ok {
+			continue
+		}
+
+		result.Total++
+		if unknown {
+			result.Unknown++
+		}
+		if page > 0 && (result.Total <= first || result.Total > first+logPageSize) {
+			continue
+		}
+
+		result.Matches = append(result.Matches, LogMatch{Line: lineno, Text: line, Segments: filter.Highlight(line)})
+		if page <= 0 && len(result.Matches) > logPageSize {
+			result.Matches = result.Matches[1:]
+		}
+	}
+
+	result.Pages = (result.Total + logPageSize - 1) / logPageSize
+	if result.Pages == 0 {
+		result.Pages = 1
+	}
+
+	result.Page = page
+	if page <= 0 || page > result.Pages {
+		result.Page = result.Pages
+	}
+
+	// The last page is only as long as what is left over
+	if page <= 0 {
+		if n := result.Total - (result.Pages-1)*logPageSize; n < len(result.Matches) {
+			result.Matches = result.Matches[len(result.Matches)-n:]
+		}
+	}
+
+	return result, nil
+}
+
+// Prev returns the previous page, or 0 if this is the first page
+func (r *LogResult) Prev() int {
+	return r.Page - 1
+}
+
+// Next returns the next page, or 0 if this is the last page
+func (r *LogResult) Next() int {
+	if r.Page >= r.Pages {
+		return 0
+	}
+	return r.Page + 1
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLogFilterSeverity(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		query   url.Values
+		line    string
+		ok      bool
+		unknown bool
+	}{
+		{
+			name:  "Worse than severity",
+			query: url.Values{"severity": {"warning"}},
+			line:  "<27>Mar  5 10:11:12 infix-00-00-00 finit[1]: Failed starting",
+			ok:    true,
+		},
+		{
+			name:  "Better than severity",
+			query: url.Values{"severity": {"warning"}},
+			line:  "<30>Mar  5 10:11:12 infix-00-00-00 finit[1]: Starting sysklogd",
+		},
+		{
+			name:    "Without priority",
+			query:   url.Values{"severity": {"warning"}},
+			line:    "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection",
+			ok:      true,
+			unknown: true,
+		},
+		{
+			name:    "Without priority, by tag",
+			query:   url.Values{"severity": {"err"}, "tag": {"dropbear"}},
+			line:    "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection",
+			ok:      true,
+			unknown: true,
+		},
+		{
+			name:  "Without priority, other tag",
+			query: url.Values{"severity": {"err"}, "tag": {"finit"}},
+			line:  "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection",
+		},
+		{
+			name:    "Not syslog",
+			query:   url.Values{"severity": {"err"}},
+			line:    "Hello, world",
+			ok:      true,
+			unknown: true,
+		},
+		{
+			name:  "Not syslog, by time",
+			query: url.Values{"severity": {"err"}, "from": {"2024-03-05T10:00"}},
+			line:  "Hello, world",
+		},
+		{
+			name:  "Without severity filter",
+			query: url.Values{"tag": {"dropbear"}},
+			line:  "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection",
+			ok:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseLogFilter(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ok, unknown := f.match(tt.line, now)
+			if ok != tt.ok || unknown != tt.unknown {
+				t.Errorf("match(%q) = %v, %v, want %v, %v", tt.line, ok, unknown, tt.ok, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestHasPriorities(t *testing.T) {
+	writeLogFiles(t, map[string]string{
+		"messages": "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection\n" +
+			"Mar  5 10:11:13 infix-00-00-00 finit[1]: Starting sysklogd\n",
+		"debug": "<30>1 2024-03-05T10:11:12.345+01:00 infix-00-00-00 finit 1 - - Starting sysklogd\n" +
+			"Mar  5 10:11:13 infix-00-00-00 dropbear[2345]: Child connection\n",
+	})
+
+	for name, want := range map[string]bool{"messages": false, "debug": true} {
+		f, err := openLogFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasPriorities(f); got != want {
+			t.Errorf("hasPriorities(%s) = %v, want %v", name, got, want)
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// RaucStatus holds the output of `rauc status --detailed`
+type RaucStatus struct {
+	Compatible  string     `json:"compatible"`
+	Variant     string     `json:"variant"`
+	Booted      string     `json:"booted"`
+	BootPrimary string     `json:"boot_primary"`
+	Slots       []RaucSlot `json:"-"`
+}
+
+// RaucSlot is a slot, its boot state and what was last installed to it
+type RaucSlot struct {
+	Name       string `json:"-"`
+	Class      string `json:"class"`
+	Device     string `json:"device"`
+	Type       string `json:"type"`
+	Bootname   string `json:"bootname"`
+	State      string `json:"state"`
+	Parent     string `json:"parent"`
+	Mountpoint string `json:"mountpoint"`
+	BootStatus string `json:"boot_status"`
+	SlotStatus struct {
+		Bundle struct {
+			Compatible  string `json:"compatible"`
+			Version     string `json:"version"`
+			Description string `json:"description"`
+			Build       string `json:"build"`
+			Hash        string `json:"hash"`
+		} `json:"bundle"`
+		Installed struct {
+			Timestamp string `json:"timestamp"`
+			Count     int    `json:"count"`
+		} `json:"installed"`
+		Activated struct {
+			Timestamp string `json:"timestamp"`
+			Count     int    `json:"count"`
+		} `json:"activated"`
+		Status string `json:"status"`
+	} `json:"slot_status"`
+	Primary bool `json:"-"`
+}
+
+// SlotInfo holds data for the boot slot page
+type SlotInfo struct {
+	Status  *RaucStatus
+	Message string
+	Error   string
+}
+
+// Actions on a slot, as `rauc status mark-<action>`
+var slotActions = map[string]string{
+	"good":   "marked good",
+	"bad":    "marked bad",
+	"active": "activated",
+}
+
+// getRaucStatus asks RAUC about the system and its slots
+func getRaucStatus() (*RaucStatus, error) {
+	output, err := raucStatusJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRaucStatus(output)
+}
+
+// raucStatusJSON returns the detailed `rauc status`, as JSON
+func raucStatusJSON() ([]byte, error) {
+	output, err := exec.Command("rauc", "status", "--detailed", "--output-format=json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute rauc command: %w", err)
+	}
+
+	return output, nil
+}
+
+// parseRaucStatus parses the JSON output of `rauc status`, where each
+// slot is an object keyed by the slot name
+func parseRaucStatus(data []byte) (*RaucStatus, error) {
+	var raw struct {
+		RaucStatus
+		Slots []map[string]RaucSlot `json:"slots"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse rauc command output: %w", err)
+	}
+
+	status := raw.RaucStatus
+	for _, slots := range raw.Slots {
+		for name, slot := range slots {
+			slot.Name = name
+			slot.Primary = name == status.BootPrimary
+			status.Slots = append(status.Slots, slot)
+		}
+	}
+
+	return &status, nil
+}
+
+// BootedSlot returns the slot the system is running from, if known
+func (status *RaucStatus) BootedSlot() *RaucSlot {
+	for i := range status.Slots {
+		if status.Slots[i].State == "booted" {
+			return &status.Slots[i]
+		}
+	}
+
+	return nil
+}
+
+// findSlot returns the named slot, if it exists
+func (status *RaucStatus) findSlot(name string) *RaucSlot {
+	for i := range status.Slots {
+		if status.Slots[i].Name == name {
+			return &status.Slots[i]
+		}
+	}
+
+	return nil
+}
+
+// slotsHandler handles the boot slot page
+func slotsHandler(w http.ResponseWriter, r *http.Request) {
+	info := &SlotInfo{}
+
+	status, err := getRaucStatus()
+	if err != nil {
+		log.Printf("Error getting RAUC status: %v", err)
+		info.Error = "Failed to get slot status from RAUC"
+	}
+	info.Status = status
+
+	renderPage(w, r, "slots", info)
+}
+
+// markSlotHandler marks a slot good, bad, or active.  Marking the
+// previous slot active is how to roll back to the previous firmware.
+func markSlotHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := r.Form.Get("slot")
+	action := r.Form.Get("action")
+	done, ok := slotActions[action]
+	if !ok {
+		http.Error(w, "Invalid slot action", http.StatusBadRequest)
+		return
+	}
+
+	status, err := getRaucStatus()
+	if err != nil {
+		log.Printf("Error getting RAUC status: %v", err)
+		http.Error(w, "Failed to get slot status", http.StatusInternalServerError)
+		return
+	}
+
+	slot := status.findSlot(name)
+	if slot == nil || slot.Bootname == "" {
+		http.Error(w, "Invalid boot slot", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Slot %s mark-%s requested by user: %s", name, action, getUsername(r))
+
+	// Changing boot order while an upgrade is writing to a slot would
+	// undo what RAUC does at the end of the installation
+	op, err := beginOperation("Boot slot change", getUsername(r))
+	if err != nil {
+		log.Printf("Refusing slot change: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer op.End()
+
+	info := &SlotInfo{}
+	output, err := exec.Command("rauc", "status", "mark-"+action, name).CombinedOutput()
+	if err != nil {
+		log.Printf("Error marking slot %s %s: %v: %s", name, action, err, output)
+		info.Error = fmt.Sprintf("Failed to mark slot %s %s: %s", name, action, strings.TrimSpace(string(output)))
+	} else {
+		info.Message = fmt.Sprintf("Slot %s (%s) %s.", name, slot.Bootname, done)
+	}
+
+	if info.Status, err = getRaucStatus(); err != nil {
+		log.Printf("Error getting RAUC status: %v", err)
+	}
+
+	renderPage(w, r, "slots", info)
+}
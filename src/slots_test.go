@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRaucStatus(t *testing.T) {
+	// `rauc status --detailed --output-format=json` of an Infix system
+	// upgraded to, and booted from, the secondary slot.  The net slot
+	// has never been installed to.
+	data, err := os.ReadFile(filepath.Join("testdata", "rauc-status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := parseRaucStatus(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Compatible != "infix-x86_64" || status.Booted != "secondary" || status.BootPrimary != "rootfs.1" {
+		t.Errorf("status = %q, booted %q, primary %q", status.Compatible, status.Booted, status.BootPrimary)
+	}
+
+	tests := []struct {
+		name       string
+		bootname   string
+		state      string
+		bootStatus string
+		primary    bool
+		version    string
+		installed  string
+		count      int
+	}{
+		{"rootfs.1", "secondary", "booted", "good", true, "v25.01.0", "2025-02-03T14:21:07Z", 3},
+		{"rootfs.0", "primary", "inactive", "good", false, "v24.11.1", "2024-12-09T08:02:44Z", 2},
+		{"net.0", "net", "inactive", "bad", false, "", "", 0},
+	}
+
+	if len(status.Slots) != len(tests) {
+		t.Fatalf("%d slots, want %d", len(status.Slots), len(tests))
+	}
+	for i, tt := range tests {
+		slot := status.Slots[i]
+		if slot.Name != tt.name || slot.Bootname != tt.bootname || slot.State != tt.state ||
+			slot.BootStatus != tt.bootStatus || slot.Primary != tt.primary {
+			t.Errorf("slot %d = %s (%s) %s, boot %s, primary %v, want %s (%s) %s, boot %s, primary %v",
+				i, slot.Name, slot.Bootname, slot.State, slot.BootStatus, slot.Primary,
+				tt.name, tt.bootname, tt.state, tt.bootStatus, tt.primary)
+		}
+		installed := slot.SlotStatus.Installed
+		if slot.SlotStatus.Bundle.Version != tt.version || installed.Timestamp != tt.installed || installed.Count != tt.count {
+			t.Errorf("%s: bundle %q installed %q (%d), want %q installed %q (%d)", slot.Name,
+				slot.SlotStatus.Bundle.Version, installed.Timestamp, installed.Count, tt.version, tt.installed, tt.count)
+		}
+	}
+
+	if booted := status.BootedSlot(); booted == nil || booted.Name != "rootfs.1" {
+		t.Errorf("BootedSlot() = %v, want rootfs.1", booted)
+	}
+	if slot := status.findSlot("rootfs.0"); slot == nil || slot.Device != "/dev/disk/by-partlabel/primary" {
+		t.Errorf("findSlot(rootfs.0) = %v", slot)
+	}
+	if slot := status.findSlot("rootfs.2"); slot != nil {
+		t.Errorf("findSlot(rootfs.2) = %v, want none", slot)
+	}
+}
+
+func TestParseRaucStatusErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"No slots", `{"compatible":"infix-x86_64","variant":"","booted":null,"boot_primary":null,"slots":[]}`, true},
+		{"Error message", "Failed to obtain status: D-Bus error", false},
+		{"Truncated", `{"compatible":"infix-x86_64","slots":[{"rootfs.0":{"class":"rootfs"`, false},
+		{"Slots not a list", `{"compatible":"infix-x86_64","slots":{"rootfs.0":{}}}`, false},
+	}
+
+	for _, tt := range tests {
+		status, err := parseRaucStatus([]byte(tt.data))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: parseRaucStatus() = %v, %v, want ok %v", tt.name, status, err, tt.ok)
+		}
+	}
+}
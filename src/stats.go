@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IFLA_STATS64, struct rtnl_link_stats64, missing in syscall
+const iflaStats64 = 23
+
+// How often interface counters are sampled, and how many samples of
+// rates are kept, i.e., five minutes of history
+const (
+	statsInterval = 2 * time.Second
+	statsHistory  = 150
+)
+
+// Size of the sparklines, in SVG user units
+const (
+	sparkWidth  = 150
+	sparkHeight = 30
+)
+
+// LinkStats is the counters of an interface
+type LinkStats struct {
+	RxPackets uint64
+	TxPackets uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+	Multicast uint64
+}
+
+// parseLinkStats parses the first counters of struct rtnl_link_stats64,
+// they are in the same order as in LinkStats
+func parseLinkStats(b []byte) LinkStats {
+	var counters [9]uint64
+	for i := range counters {
+		if len(b) < (i+1)*8 {
+			break
+		}
+		counters[i] = binary.NativeEndian.Uint64(b[i*8:])
+	}
+
+	return LinkStats{
+		RxPackets: counters[0],
+		TxPackets: counters[1],
+		RxBytes:   counters[2],
+		TxBytes:   counters[3],
+		RxErrors:  counters[4],
+		TxErrors:  counters[5],
+		RxDropped: counters[6],
+		TxDropped: counters[7],
+		Multicast: counters[8],
+	}
+}
+
+// sub returns the counters since a baseline.  Counters reset, e.g.,
+// when a driver is reloaded, count from zero instead.
+func (s LinkStats) sub(base LinkStats) LinkStats {
+	diff := func(a, b uint64) uint64 {
+		if a < b {
+			return a
+		}
+		return a - b
+	}
+
+	return LinkStats{
+		RxPackets: diff(s.RxPackets, base.RxPackets),
+		TxPackets: diff(s.TxPackets, base.TxPackets),
+		RxBytes:   diff(s.RxBytes, base.RxBytes),
+		TxBytes:   diff(s.TxBytes, base.TxBytes),
+		RxErrors:  diff(s.RxErrors, base.RxErrors),
+		TxErrors:  diff(s.TxErrors, base.TxErrors),
+		RxDropped: diff(s.RxDropped, base.RxDropped),
+		TxDropped: diff(s.TxDropped, base.TxDropped),
+		Multicast: diff(s.Multicast, base.Multicast),
+	}
+}
+
+// linkHistory is the sampled counters and rates of an interface
+type linkHistory struct {
+	last     LinkStats
+	sampled  time.Time
+	baseline LinkStats
+	cleared  time.Time
+	rxRates  []float64
+	txRates  []float64
+}
+
+// InterfaceStats is the counters and throughput of an interface
+type InterfaceStats struct {
+	Name     string
+	Counters LinkStats
+	Cleared  time.Time
+	RxRate   float64
+	TxRate   float64
+	RxPoints string
+	TxPoints string
+	Peak     float64
+}
+
+// StatsInfo holds data for the interface statistics
+type StatsInfo struct {
+	Interfaces []InterfaceStats
+	Interval   time.Duration
+}
+
+var (
+	statsMutex sync.Mutex
+	statsLinks = make(map[string]*linkHistory)
+)
+
+// startStatsSampler samples the counters of all interfaces, in the
+// background, for all viewers of the network page
+func startStatsSampler() {
+	go func() {
+		for {
+			if err := sampleStats(); err != nil {
+				log.Printf("Error sampling interface counters: %v", err)
+			}
+			time.Sleep(statsInterval)
+		}
+	}()
+}
+
+// sampleStats reads the counters of all interfaces and adds their
+// rates, in bits per second, to the history
+func sampleStats() error {
+	links, _, err := getLinks()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	seen := make(map[string]bool)
+	for _, l := range links {
+		seen[l.Name] = true
+
+		h, ok := statsLinks[l.Name]
+		if !ok {
+			statsLinks[l.Name] = &linkHistory{last: l.Stats, sampled: now}
+			continue
+		}
+		h.sample(l.Stats, now)
+	}
+
+	// Forget removed interfaces, e.g., deleted VLANs
+	for name := range statsLinks {
+		if !seen[name] {
+			delete(statsLinks, name)
+		}
+	}
+
+	return nil
+}
+
+// sample adds the rates since the last sample to the history, over the
+// time measured between the samples, since reading the counters of all
+// interfaces, or the sampler itself, may be late
+func (h *linkHistory) sample(stats LinkStats, now time.Time) {
+	if seconds := now.Sub(h.sampled).Seconds(); seconds > 0 {
+		h.rxRates = appendRate(h.rxRates, stats.RxBytes, h.last.RxBytes, seconds)
+		h.txRates = appendRate(h.txRates, stats.TxBytes, h.last.TxBytes, seconds)
+	}
+	h.last, h.sampled = stats, now
+}
+
+// appendRate adds the rate of a byte counter to a history
+func appendRate(rates []float64, now, last uint64, seconds float64) []float64 {
+	var rate float64
+	if now >= last {
+		rate = float64(now-last) * 8 / seconds
+	}
+
+	rates = append(rates, rate)
+	if len(rates) > statsHistory {
+		rates = rates[len(rates)-statsHistory:]
+	}
+	return rates
+}
+
+// sparkline returns the points of an SVG polyline of rates, scaled to
+// a peak, the latest rate to the right
+func sparkline(rates []float64, peak float64) string {
+	var points []string
+
+	step := float64(sparkWidth) / float64(statsHistory-1)
+	start := statsHistory - len(rates)
+	for i, rate := range rates {
+		y := float64(sparkHeight)
+		if peak > 0 {
+			y -= rate / peak * sparkHeight
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", float64(start+i)*step, y))
+	}
+
+	return strings.Join(points, " ")
+}
+
+// getStats returns the counters, since they were cleared, and the
+// throughput of all interfaces
+func getStats() []InterfaceStats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	var stats []InterfaceStats
+	for name, h := range statsLinks {
+		s := InterfaceStats{
+			Name:     name,
+			Counters: h.last.sub(h.baseline),
+			Cleared:  h.cleared,
+		}
+
+		for i := range h.rxRates {
+			s.Peak = max(s.Peak, h.rxRates[i], h.txRates[i])
+		}
+		if n := len(h.rxRates); n > 0 {
+			s.RxRate, s.TxRate = h.rxRates[n-1], h.txRates[n-1]
+		}
+		s.RxPoints = sparkline(h.rxRates, s.Peak)
+		s.TxPoints = sparkline(h.txRates, s.Peak)
+
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Name == "lo" {
+			return stats[j].Name != "lo"
+		}
+		if stats[j].Name == "lo" {
+			return false
+		}
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats
+}
+
+// getInterfaceStats returns the counters and throughput of an
+// interface, or nil if it has not been sampled
+func getInterfaceStats(name string) *InterfaceStats {
+	for _, s := range getStats() {
+		if s.Name == name {
+			return &s
+		}
+	}
+	return nil
+}
+
+// clearCounters sets the baseline of the counters of an interface, or
+// all interfaces, to their current values.  The kernel counters are
+// not touched.
+func clearCounters(name string) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	for n, h := range statsLinks {
+		if name == "" || name == n {
+			h.baseline = h.last
+			h.cleared = time.Now()
+		}
+	}
+}
+
+// formatBits formats a rate in bits per second
+func formatBits(bps float64) string {
+	switch {
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbit/s", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbit/s", bps/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.1f kbit/s", bps/1e3)
+	}
+	return fmt.Sprintf("%.0f bit/s", bps)
+}
+
+// RxRateString returns the receive rate for display
+func (s InterfaceStats) RxRateString() string {
+	return formatBits(s.RxRate)
+}
+
+// TxRateString returns the transmit rate for display
+func (s InterfaceStats) TxRateString() string {
+	return formatBits(s.TxRate)
+}
+
+// PeakString returns the peak rate of the history for display
+func (s InterfaceStats) PeakString() string {
+	return formatBits(s.Peak)
+}
+
+// RxBytesString returns the received bytes for display
+func (s InterfaceStats) RxBytesString() string {
+	return formatSize(s.Counters.RxBytes)
+}
+
+// TxBytesString returns the transmitted bytes for display
+func (s InterfaceStats) TxBytesString() string {
+	return formatSize(s.Counters.TxBytes)
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	renderFragment(w, "net-stats", &StatsInfo{
+		Interfaces: getStats(),
+		Interval:   statsInterval,
+	})
+}
+
+func clearCountersHandler(w http.ResponseWriter, r *http.Request) {
+	clearCounters(r.FormValue("ifname"))
+	statsHandler(w, r)
+}
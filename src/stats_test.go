@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLinkHistorySample(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := &linkHistory{sampled: start}
+
+	// A late sample, 4 s after the last, is a rate over 4 s
+	h.sample(LinkStats{RxBytes: 1000, TxBytes: 500}, start.Add(4*time.Second))
+	h.sample(LinkStats{RxBytes: 1250, TxBytes: 500}, start.Add(5*time.Second))
+	// No time between samples, e.g., the clock stepped back, adds no rate
+	h.sample(LinkStats{RxBytes: 2000, TxBytes: 600}, start.Add(5*time.Second))
+	// A counter reset is no traffic
+	h.sample(LinkStats{RxBytes: 100, TxBytes: 1000}, start.Add(7*time.Second))
+
+	if want := []float64{2000, 2000, 0}; !reflect.DeepEqual(h.rxRates, want) {
+		t.Errorf("rx rates = %v, want %v", h.rxRates, want)
+	}
+	if want := []float64{1000, 0, 1600}; !reflect.DeepEqual(h.txRates, want) {
+		t.Errorf("tx rates = %v, want %v", h.txRates, want)
+	}
+	if h.last.RxBytes != 100 || !h.sampled.Equal(start.Add(7*time.Second)) {
+		t.Errorf("last sample = %+v at %v", h.last, h.sampled)
+	}
+}
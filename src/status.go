@@ -157,13 +157,17 @@ func getVersionInfo() map[string]string {
 	return versionInfo
 }
 
-// getSystemModel gets the system model from DMI
+// getSystemModel gets the system model from DMI, or the device tree on
+// systems without DMI, e.g., most Arm boards
 func getSystemModel() string {
 	data, err := ioutil.ReadFile("/sys/devices/virtual/dmi/id/product_family")
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		data, err = ioutil.ReadFile("/sys/firmware/devicetree/base/model")
+	}
 	if err != nil {
 		return "Unknown"
 	}
-	return strings.TrimSpace(string(data))
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
 }
 
 // getCPUInfo gets CPU information
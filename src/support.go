@@ -0,0 +1,427 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// supportFile is a file of the support bundle, and how to collect it
+type supportFile struct {
+	Name    string
+	Collect func() ([]byte, error)
+}
+
+// Everything but the logs in a support bundle
+var supportFiles = []supportFile{
+	{"os-release", func() ([]byte, error) { return os.ReadFile("/etc/os-release") }},
+	{"rauc-status.json", raucStatusJSON},
+	{"ip-addr.json", func() ([]byte, error) { return ipJSON("addr") }},
+	{"ip-route.json", func() ([]byte, error) { return ipJSON("route") }},
+	{"ip-route6.json", func() ([]byte, error) { return ipJSON("-6", "route") }},
+	{"sysrepo-running.xml", func() ([]byte, error) { return sysrepoXML(sr.DSRunning) }},
+	{"sysrepo-operational.xml", func() ([]byte, error) { return sysrepoXML(sr.DSOperational) }},
+	{"dmesg.txt", func() ([]byte, error) { return exec.Command("dmesg").Output() }},
+	{"processes.txt", listProcesses},
+}
+
+// redaction replaces a secret in the files of a support bundle
+type redaction struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// Secrets that must not leave the device
+var redactions = []redaction{
+	// Private keys in PEM format
+	{
+		regexp.MustCompile(`(?s)-----BEGIN ([A-Z ]*)PRIVATE KEY-----.*?-----END ([A-Z ]*)PRIVATE KEY-----`),
+		"-----BEGIN ${1}PRIVATE KEY-----\nREDACTED\n-----END ${2}PRIVATE KEY-----",
+	},
+	// Password hashes, keys and shared secrets in the YANG data
+	{
+		regexp.MustCompile(`<((?:[\w-]+:)?[\w-]*(?:password|private-key|symmetric-key|pre-shared-key|secret|psk))(\s[^>]*)?>[^<]+</`),
+		"<$1$2>REDACTED</",
+	},
+	// crypt(3) password hashes, e.g., $6$salt$hash
+	{
+		regexp.MustCompile(`\$(?:1|2[abxy]?|5|6|y|gy|7)\$[./0-9A-Za-z$]{8,}`),
+		"REDACTED",
+	},
+}
+
+// redact replaces secrets in data, returns the number of secrets found
+func redact(data []byte) ([]byte, int) {
+	count := 0
+	for _, r := range redactions {
+		count += len(r.re.FindAllIndex(data, -1))
+		data = r.re.ReplaceAll(data, []byte(r.repl))
+	}
+
+	return data, count
+}
+
+// sysrepoXML returns all data of a sysrepo datastore
+func sysrepoXML(ds sr.Datastore) ([]byte, error) {
+	data, err := getDataXML(ds, "/*")
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(data), nil
+}
+
+// listProcesses returns a process list, like ps
+func listProcesses() ([]byte, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].Pid < procs[j].Pid
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%6s %6s %-10s %-6s %8s  %s\n", "PID", "PPID", "USER", "STAT", "RSS", "COMMAND")
+	for _, p := range procs {
+		ppid, _ := p.Ppid()
+		user, _ := p.Username()
+		status, _ := p.Status()
+
+		rss := "-"
+		if mem, err := p.MemoryInfo(); err == nil {
+			rss = formatSize(mem.RSS)
+		}
+
+		cmd, err := p.Cmdline()
+		if err != nil || cmd == "" {
+			// Kernel threads have no command line
+			name, _ := p.Name()
+			cmd = "[" + name + "]"
+		}
+
+		fmt.Fprintf(&b, "%6d %6d %-10s %-6s %8s  %s\n", p.Pid, ppid, user, strings.Join(status, ""), rss, cmd)
+	}
+
+	return []byte(b.String()), nil
+}
+
+// supportArchive is a tar.gz archive streamed to the browser, with a
+// manifest of what was, or could not be, collected
+type supportArchive struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	dir      string
+	now      time.Time
+	files    []string
+	failures []string
+}
+
+// newSupportArchive starts an archive, all files are in dir
+func newSupportArchive(w io.Writer, dir string) *supportArchive {
+	gz := gzip.NewWriter(w)
+	return &supportArchive{
+		gz:  gz,
+		tw:  tar.NewWriter(gz),
+		dir: dir,
+		now: time.Now(),
+	}
+}
+
+// add adds a collected file, with secrets redacted, or records why it
+// could not be collected
+func (a *supportArchive) add(name string, data []byte, err error) error {
+	if err != nil {
+		a.failures = append(a.failures, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+
+	data, redacted := redact(data)
+
+	note := ""
+	if redacted > 0 {
+		note = fmt.Sprintf("  (%d redacted)", redacted)
+	}
+	a.files = append(a.files, fmt.Sprintf("%10d  %x  %s%s", len(data), sha256.Sum256(data), name, note))
+
+	return a.write(name, data)
+}
+
+// write writes a file to the archive, as is
+func (a *supportArchive) write(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    a.dir + "/" + name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: a.now,
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := a.tw.Write(data)
+	return err
+}
+
+// addLogs adds all log files in /var/log, compressed ones decompressed
+func (a *supportArchive) addLogs() error {
+	files, err := listLogFiles()
+	if err != nil {
+		return a.add("var/log", nil, err)
+	}
+
+	for _, file := range files {
+		name := "var/log/" + strings.TrimSuffix(file, ".gz")
+
+		f, err := openLogFile(file)
+		if err != nil {
+			a.failures = append(a.failures, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		err = a.addLog(name, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addLog adds a log file, with secrets redacted, without reading it
+// into memory.  It is streamed twice, for the size and checksum of the
+// file, then to the archive.
+func (a *supportArchive) addLog(name string, f logFile) error {
+	sum := sha256.New()
+	size, redacted, err := redactLog(sum, f)
+	if err != nil {
+		a.failures = append(a.failures, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+
+	note := ""
+	if redacted > 0 {
+		note = fmt.Sprintf("  (%d redacted)", redacted)
+	}
+	a.files = append(a.files, fmt.Sprintf("%10d  %x  %s%s", size, sum.Sum(nil), name, note))
+
+	hdr := &tar.Header{
+		Name:    a.dir + "/" + name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: a.now,
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	w := &entryWriter{w: a.tw, left: size}
+	_, _, err = redactLog(w, f)
+	if w.err != nil {
+		return w.err
+	}
+	if err != nil || w.left > 0 || w.over {
+		// Changed since it was first read, keep the archive whole
+		a.failures = append(a.failures, fmt.Sprintf("%s: changed while collected", name))
+		_, err := a.tw.Write(bytes.Repeat([]byte{'\n'}, int(w.left)))
+		return err
+	}
+
+	return nil
+}
+
+// redactLog writes a log file, with secrets redacted, line by line, or
+// by private key, returns the size written and the number of secrets
+func redactLog(w io.Writer, f logFile) (int64, int, error) {
+	stream, err := f.Stream()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stream.Close()
+
+	var size int64
+	count := 0
+	emit := func(data []byte) error {
+		data, n := redact(data)
+		count += n
+		k, err := w.Write(data)
+		size += int64(k)
+		return err
+	}
+
+	var key []byte
+	reader := bufio.NewReader(stream)
+	for {
+		line, rerr := reader.ReadBytes('\n')
+
+		switch {
+		case key != nil:
+			key = append(key, line...)
+			if pemEnd.Match(line) || len(key) > maxKeySize {
+				err = emit(key)
+				key = nil
+			}
+		case pemBegin.Match(line) && !pemEnd.Match(line):
+			key = line
+		default:
+			err = emit(line)
+		}
+		if err != nil {
+			return size, count, err
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return size, count, rerr
+		}
+	}
+	if key != nil {
+		if err := emit(key); err != nil {
+			return size, count, err
+		}
+	}
+
+	return size, count, nil
+}
+
+// Private keys in PEM format, redacted as a whole in logs
+var (
+	pemBegin = regexp.MustCompile(`-----BEGIN ([A-Z ]*)PRIVATE KEY-----`)
+	pemEnd   = regexp.MustCompile(`-----END ([A-Z ]*)PRIVATE KEY-----`)
+)
+
+// Largest private key in a log, longer ones are not redacted as one
+const maxKeySize = 64 * 1024
+
+// entryWriter writes up to the size of an entry of an archive, the size
+// of the file when first read
+type entryWriter struct {
+	w    io.Writer
+	left int64
+	over bool
+	err  error
+}
+
+func (e *entryWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if int64(n) > e.left {
+		p = p[:e.left]
+		e.over = true
+	}
+
+	k, err := e.w.Write(p)
+	e.left -= int64(k)
+	if err != nil {
+		e.err = err
+		return k, err
+	}
+	return n, nil
+}
+
+// Close adds the manifest and ends the archive
+func (a *supportArchive) Close(title, user string) error {
+	var b strings.Builder
+
+	version := getVersionInfo()
+	fmt.Fprintf(&b, "%s\n\n", title)
+	fmt.Fprintf(&b, "Model:     %s\n", getSystemModel())
+	fmt.Fprintf(&b, "Version:   %s\n", version["PRETTY_NAME"])
+	fmt.Fprintf(&b, "Uptime:    %s\n", getUptime())
+	fmt.Fprintf(&b, "Generated: %s by %s\n\n", a.now.Format(time.RFC3339), user)
+	fmt.Fprintf(&b, "Secrets, e.g., password hashes and private keys, are replaced with REDACTED.\n\n")
+
+	fmt.Fprintf(&b, "Files (size, SHA-256, name):\n")
+	for _, line := range a.files {
+		fmt.Fprintf(&b, "%s\n", line)
+	}
+
+	if len(a.failures) > 0 {
+		fmt.Fprintf(&b, "\nNot collected:\n")
+		for _, line := range a.failures {
+			fmt.Fprintf(&b, "%s\n", line)
+		}
+	}
+
+	if err := a.write("MANIFEST.txt", []byte(b.String())); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+
+	return a.gz.Close()
+}
+
+// startArchiveDownload sends the headers of an archive download, and
+// returns the name of the archive, without extension
+func startArchiveDownload(w http.ResponseWriter, kind string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "device"
+	}
+	name := fmt.Sprintf("%s-%s-%s", kind, hostname, time.Now().Format("20060102-150405"))
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", name))
+	w.Header().Set("Cache-Control", "no-store")
+
+	return name
+}
+
+// downloadLogsHandler streams all log files as one archive
+func downloadLogsHandler(w http.ResponseWriter, r *http.Request) {
+	name := startArchiveDownload(w, "logs")
+	archive := newSupportArchive(w, name)
+
+	err := archive.addLogs()
+	if err == nil {
+		err = archive.Close("Log files", getUsername(r))
+	}
+	if err != nil {
+		// Too late for an error page, the browser gets a broken archive
+		log.Printf("Error sending log archive: %v", err)
+	}
+}
+
+// supportBundleHandler streams a support bundle: the logs and the
+// state of the system, for troubleshooting by the vendor
+func supportBundleHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Support bundle requested by %s", getUsername(r))
+
+	name := startArchiveDownload(w, "support")
+	archive := newSupportArchive(w, name)
+
+	err := archive.addLogs()
+	for _, file := range supportFiles {
+		if err != nil {
+			break
+		}
+
+		data, cerr := file.Collect()
+		err = archive.add(file.Name, data, cerr)
+	}
+	if err == nil {
+		err = archive.Close("Support bundle", getUsername(r))
+	}
+	if err != nil {
+		log.Printf("Error sending support bundle: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogEntry is a parsed syslog line
+type SyslogEntry struct {
+	Time     time.Time
+	Host     string
+	App      string
+	PID      string
+	MsgID    string
+	Data     string
+	Facility int
+	Severity int
+	Message  string
+}
+
+// Syslog severities, most severe first
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Syslog facilities, by number, as named by ietf-syslog, for the log
+// viewer and the log settings alike
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "console", "cron2",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Layout of BSD syslog (RFC 3164) timestamps, without a year
+const bsdTimestamp = "Jan _2 15:04:05"
+
+// Layout of timestamps in the log table
+const logTimestamp = "2006-01-02 15:04:05"
+
+// parseSeverity returns the severity level of a name, or -1
+func parseSeverity(name string) int {
+	for i, sev := range severityNames {
+		if strings.EqualFold(name, sev) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// SeverityName returns the name of the severity, if known
+func (e *SyslogEntry) SeverityName() string {
+	if e.Severity < 0 || e.Severity >= len(severityNames) {
+		return ""
+	}
+	return severityNames[e.Severity]
+}
+
+// SeverityClass returns the Bootstrap class of the severity badge
+func (e *SyslogEntry) SeverityClass() string {
+	return severityClass(e.Severity)
+}
+
+// RowClass returns the Bootstrap class of the entry in the log table
+func (e *SyslogEntry) RowClass() string {
+	return severityRowClass(e.Severity)
+}
+
+// severityClass returns the Bootstrap class of a severity badge
+func severityClass(severity int) string {
+	switch {
+	case severity < 0:
+		return ""
+	case severity <= 3:
+		return "bg-danger"
+	case severity == 4:
+		return "bg-warning text-dark"
+	case severity == 5:
+		return "bg-info text-dark"
+	case severity == 7:
+		return "bg-light text-dark"
+	}
+	return "bg-secondary"
+}
+
+// severityRowClass returns the Bootstrap class of a table row with a
+// message of the severity, errors and warnings stand out, debug fades
+func severityRowClass(severity int) string {
+	switch {
+	case severity < 0:
+		return ""
+	case severity <= 3:
+		return "table-danger"
+	case severity == 4:
+		return "table-warning"
+	case severity == 7:
+		return "text-muted"
+	}
+	return ""
+}
+
+// FacilityName returns the name of the facility, if known
+func (e *SyslogEntry) FacilityName() string {
+	if e.Facility < 0 || e.Facility >= len(facilityNames) {
+		return ""
+	}
+	return facilityNames[e.Facility]
+}
+
+// TimeString returns the timestamp as shown in the log table
+func (e *SyslogEntry) TimeString() string {
+	if e.Time.IsZero() {
+		return ""
+	}
+	return e.Time.Format(logTimestamp)
+}
+
+// parsePriority parses an optional "<PRI>" prefix, returns the facility
+// and severity, or -1 if there is no priority, and the rest of the line
+func parsePriority(line string) (int, int, string) {
+	if !strings.HasPrefix(line, "<") {
+		return -1, -1, line
+	}
+
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return -1, -1, line
+	}
+
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return -1, -1, line
+	}
+
+	return pri / 8, pri % 8, line[end+1:]
+}
+
+// parseSyslogLine parses a line as written to /var/log by syslogd,
+// optionally prefixed with the priority.  BSD syslog (RFC 3164) lines,
+// the same with an RFC 3339 timestamp, and RFC 5424 lines are handled:
+//
+//	[<PRI>]Mmm dd hh:mm:ss host app[pid]: message
+//	[<PRI>]2006-01-02T15:04:05.000+01:00 host app[pid]: message
+//	[<PRI>1 ]2006-01-02T15:04:05.000+01:00 host app procid msgid [sd] message
+//
+// BSD timestamps have no year, lines from the future are from last
+// year.  Returns false if the line is not in syslog format.
+func parseSyslogLine(line string, now time.Time) (*SyslogEntry, bool) {
+	entry := &SyslogEntry{}
+	entry.Facility, entry.Severity, line = parsePriority(line)
+
+	// RFC 5424 has a version after the priority
+	version := entry.Severity >= 0 && strings.HasPrefix(line, "1 ")
+	if version {
+		line = line[2:]
+	}
+
+	stamp, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return nil, false
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+		entry.Time = ts.Local()
+	} else if !(version && stamp == "-") {
+		return parseBSDLine(entry, line, now)
+	}
+
+	host, rest, ok := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	if !ok {
+		return nil, false
+	}
+	entry.Host = nilValue(host)
+
+	// Without a version, sysklogd uses the RFC 5424 format, or the
+	// BSD format with an RFC 3339 timestamp
+	if !version {
+		if tag, _, _ := strings.Cut(rest, " "); strings.HasSuffix(tag, ":") {
+			parseTag(entry, rest)
+			return entry, true
+		}
+	}
+
+	fields := strings.SplitN(rest, " ", 4)
+	if len(fields) < 4 {
+		return nil, false
+	}
+	entry.App = nilValue(fields[0])
+	entry.PID = nilValue(fields[1])
+	entry.MsgID = nilValue(fields[2])
+
+	data, msg := splitStructuredData(fields[3])
+	entry.Data = nilValue(data)
+	entry.Message = strings.TrimPrefix(msg, "\ufeff")
+
+	return entry, true
+}
+
+// parseBSDLine parses the rest of a BSD syslog line, after the priority
+func parseBSDLine(entry *SyslogEntry, line string, now time.Time) (*SyslogEntry, bool) {
+	if len(line) < len(bsdTimestamp)+1 {
+		return nil, false
+	}
+
+	ts, err := time.ParseInLocation(bsdTimestamp, line[:len(bsdTimestamp)], time.Local)
+	if err != nil {
+		return nil, false
+	}
+
+	entry.Time = time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, time.Local)
+	if entry.Time.After(now.AddDate(0, 0, 1)) {
+		entry.Time = entry.Time.AddDate(-1, 0, 0)
+	}
+
+	host, rest, ok := strings.Cut(strings.TrimLeft(line[len(bsdTimestamp):], " "), " ")
+	if !ok {
+		return nil, false
+	}
+	entry.Host = host
+
+	parseTag(entry, rest)
+	return entry, true
+}
+
+// parseTag parses the "app[pid]: message" part of a BSD syslog line
+func parseTag(entry *SyslogEntry, rest string) {
+	// The tag ends with a colon, but messages without a tag exist
+	tag, msg, ok := strings.Cut(rest, ": ")
+	if !ok {
+		tag, ok = strings.CutSuffix(rest, ":")
+	}
+	if !ok || tag == "" || strings.ContainsAny(tag, " \t") {
+		entry.Message = rest
+		return
+	}
+
+	entry.App = tag
+	if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+		entry.App = tag[:i]
+		entry.PID = tag[i+1 : len(tag)-1]
+	}
+	entry.Message = msg
+}
+
+// splitStructuredData splits RFC 5424 structured data, "-" or one or
+// more [id name="value"...] elements, from the message
+func splitStructuredData(s string) (string, string) {
+	if !strings.HasPrefix(s, "[") {
+		data, msg, _ := strings.Cut(s, " ")
+		return data, msg
+	}
+
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ']' && !quoted:
+			if i+1 < len(s) && s[i+1] == '[' {
+				continue
+			}
+			return s[:i+1], strings.TrimPrefix(s[i+1:], " ")
+		}
+	}
+
+	// Unterminated, all of it is data
+	return s, ""
+}
+
+// nilValue returns the value of an RFC 5424 field, "-" is no value
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
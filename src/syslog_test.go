@@ -0,0 +1,218 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSyslogLine(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+		want SyslogEntry
+	}{
+		{
+			name: "BSD without priority",
+			line: "Mar  5 10:11:12 infix-00-00-00 dropbear[2345]: Child connection from 192.168.2.1:52936",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 10, 11, 12, 0, time.Local),
+				Host:     "infix-00-00-00",
+				App:      "dropbear",
+				PID:      "2345",
+				Facility: -1,
+				Severity: -1,
+				Message:  "Child connection from 192.168.2.1:52936",
+			},
+		},
+		{
+			name: "BSD with priority",
+			line: "<30>Mar  5 10:11:12 infix-00-00-00 finit[1]: Starting sysklogd[123]",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 10, 11, 12, 0, time.Local),
+				Host:     "infix-00-00-00",
+				App:      "finit",
+				PID:      "1",
+				Facility: 3,
+				Severity: 6,
+				Message:  "Starting sysklogd[123]",
+			},
+		},
+		{
+			name: "BSD without PID",
+			line: "<4>Mar  5 10:11:12 infix-00-00-00 kernel: e1: Link is Down",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 10, 11, 12, 0, time.Local),
+				Host:     "infix-00-00-00",
+				App:      "kernel",
+				Facility: 0,
+				Severity: 4,
+				Message:  "e1: Link is Down",
+			},
+		},
+		{
+			name: "BSD from last year",
+			line: "Dec 31 23:59:59 infix-00-00-00 chronyd[456]: Selected source 192.168.2.1",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2023, time.December, 31, 23, 59, 59, 0, time.Local),
+				Host:     "infix-00-00-00",
+				App:      "chronyd",
+				PID:      "456",
+				Facility: -1,
+				Severity: -1,
+				Message:  "Selected source 192.168.2.1",
+			},
+		},
+		{
+			name: "BSD with RFC 3339 timestamp",
+			line: "2024-03-05T10:11:12.345678+01:00 infix-00-00-00 netopeer2-server[1234]: Session 5 created.",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 9, 11, 12, 345678000, time.UTC),
+				Host:     "infix-00-00-00",
+				App:      "netopeer2-server",
+				PID:      "1234",
+				Facility: -1,
+				Severity: -1,
+				Message:  "Session 5 created.",
+			},
+		},
+		{
+			name: "RFC 5424 with nil fields",
+			line: "<13>1 2024-03-05T10:11:12.345678+01:00 infix-00-00-00 confd 2101 - - Loaded 12 modules",
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 9, 11, 12, 345678000, time.UTC),
+				Host:     "infix-00-00-00",
+				App:      "confd",
+				PID:      "2101",
+				Facility: 1,
+				Severity: 5,
+				Message:  "Loaded 12 modules",
+			},
+		},
+		{
+			name: "RFC 5424 with structured data",
+			line: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] An application event log entry`,
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+				Host:     "mymachine.example.com",
+				App:      "evntslog",
+				MsgID:    "ID47",
+				Data:     `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`,
+				Facility: 20,
+				Severity: 5,
+				Message:  "An application event log entry",
+			},
+		},
+		{
+			name: "RFC 5424 with escaped bracket in data",
+			line: `<86>1 2024-03-05T10:11:12Z infix-00-00-00 sshd 987 AUTH [meta note="a \] b"] Accepted publickey`,
+			ok:   true,
+			want: SyslogEntry{
+				Time:     time.Date(2024, time.March, 5, 10, 11, 12, 0, time.UTC),
+				Host:     "infix-00-00-00",
+				App:      "sshd",
+				PID:      "987",
+				MsgID:    "AUTH",
+				Data:     `[meta note="a \] b"]`,
+				Facility: 10,
+				Severity: 6,
+				Message:  "Accepted publickey",
+			},
+		},
+		{
+			name: "RFC 5424 with nil timestamp",
+			line: "<14>1 - infix-00-00-00 app - - - No time",
+			ok:   true,
+			want: SyslogEntry{
+				Host:     "infix-00-00-00",
+				App:      "app",
+				Facility: 1,
+				Severity: 6,
+				Message:  "No time",
+			},
+		},
+		{
+			name: "Truncated BSD timestamp",
+			line: "Mar  5 10:1",
+		},
+		{
+			name: "Truncated RFC 5424",
+			line: "<13>1 2024-03-05T10:11:12Z infix-00-00-00 confd",
+		},
+		{
+			name: "Not syslog",
+			line: "Hello, world",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSyslogLine(tt.line, now)
+			if ok != tt.ok {
+				t.Fatalf("parseSyslogLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, tt.want.Time)
+			}
+			if got.Host != tt.want.Host {
+				t.Errorf("Host = %q, want %q", got.Host, tt.want.Host)
+			}
+			if got.App != tt.want.App {
+				t.Errorf("App = %q, want %q", got.App, tt.want.App)
+			}
+			if got.PID != tt.want.PID {
+				t.Errorf("PID = %q, want %q", got.PID, tt.want.PID)
+			}
+			if got.MsgID != tt.want.MsgID {
+				t.Errorf("MsgID = %q, want %q", got.MsgID, tt.want.MsgID)
+			}
+			if got.Data != tt.want.Data {
+				t.Errorf("Data = %q, want %q", got.Data, tt.want.Data)
+			}
+			if got.Facility != tt.want.Facility || got.Severity != tt.want.Severity {
+				t.Errorf("Facility, Severity = %d, %d, want %d, %d",
+					got.Facility, got.Severity, tt.want.Facility, tt.want.Severity)
+			}
+			if got.Message != tt.want.Message {
+				t.Errorf("Message = %q, want %q", got.Message, tt.want.Message)
+			}
+		})
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		line     string
+		facility int
+		severity int
+		rest     string
+	}{
+		{"<0>msg", 0, 0, "msg"},
+		{"<191>msg", 23, 7, "msg"},
+		{"<192>msg", -1, -1, "<192>msg"},
+		{"<>msg", -1, -1, "<>msg"},
+		{"<abc>msg", -1, -1, "<abc>msg"},
+		{"msg", -1, -1, "msg"},
+	}
+
+	for _, tt := range tests {
+		facility, severity, rest := parsePriority(tt.line)
+		if facility != tt.facility || severity != tt.severity || rest != tt.rest {
+			t.Errorf("parsePriority(%q) = %d, %d, %q, want %d, %d, %q",
+				tt.line, facility, severity, rest, tt.facility, tt.severity, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ly "github.com/mattiaswal/go-libyang/libyang"
+	sr "github.com/mattiaswal/go-sysrepo/sysrepo"
+)
+
+// How long to wait for sysrepo to apply, and save, changes
+const sysrepoTimeout = 10 * time.Second
+
+// errNoData is returned when there is no data for an xpath
+var errNoData = errors.New("no data")
+
+// sysrepoSession connects to sysrepo and starts a session with a
+// datastore, the returned function ends the session and disconnects
+func sysrepoSession(ds sr.Datastore) (*sr.Session, func(), error) {
+	conn, err := sr.Connect(sr.ConnDefault)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to sysrepo: %w", err)
+	}
+
+	sess, err := conn.SessionStart(ds)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to start sysrepo session: %w", err)
+	}
+
+	return sess, func() {
+		sess.Close()
+		conn.Close()
+	}, nil
+}
+
+// getData prints the data selected by xpath from a datastore.  With
+// all set, all top-level trees are printed, one after the other, which
+// only makes sense for XML.  Otherwise only the first is.
+func getData(ds sr.Datastore, xpath string, format ly.DataFormat, all bool) (data string, err error) {
+	sess, done, err := sysrepoSession(ds)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	// GetData dereferences the result without checking it, which
+	// fails when there is no data at all
+	defer func() {
+		if r := recover(); r != nil {
+			err = errNoData
+		}
+	}()
+
+	node, err := sess.GetData(xpath, 0, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	if node.Ptr == nil {
+		return "", errNoData
+	}
+
+	var b strings.Builder
+	for n := node; n.Ptr != nil; n = n.Next() {
+		tree, err := n.Print(format)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(tree)
+
+		if !all {
+			break
+		}
+	}
+
+	return b.String(), nil
+}
+
+// getDataXML returns the data selected by xpath from a datastore, all
+// top-level trees, as XML
+func getDataXML(ds sr.Datastore, xpath string) (string, error) {
+	return getData(ds, xpath, ly.DataFormatXML, true)
+}
+
+// getDataJSON returns the data selected by xpath from a datastore as
+// JSON, xpath should select data of a single module
+func getDataJSON(ds sr.Datastore, xpath string) ([]byte, error) {
+	data, err := getData(ds, xpath, ly.DataFormatJSON, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(data), nil
+}
+
+// editConfig applies the changes made by edit to the running
+// configuration.  Changes are validated against the YANG models by
+// sysrepo when applied, and lost at reboot unless saved to startup,
+// see saveConfig.
+func editConfig(edit func(sess *sr.Session) error) error {
+	sess, done, err := sysrepoSession(sr.DSRunning)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := edit(sess); err != nil {
+		sess.DiscardChanges(nil)
+		return err
+	}
+
+	if err := sess.ApplyChanges(sysrepoTimeout); err != nil {
+		sess.DiscardChanges(nil)
+		return err
+	}
+
+	return nil
+}
+
+// saveConfig saves the running configuration as startup configuration
+func saveConfig() error {
+	sess, done, err := sysrepoSession(sr.DSStartup)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return sess.CopyConfig(sr.DSRunning, nil, sysrepoTimeout)
+}
+
+// unsavedConfig reports whether the running configuration differs
+// from the startup configuration
+func unsavedConfig() (bool, error) {
+	running, err := getDataXML(sr.DSRunning, "/*")
+	if err != nil && err != errNoData {
+		return false, err
+	}
+	startup, err := getDataXML(sr.DSStartup, "/*")
+	if err != nil && err != errNoData {
+		return false, err
+	}
+
+	return running != startup, nil
+}
+
+// sysrepoErrors returns the errors sysrepo reported for a failed edit,
+// e.g., by YANG validation, with the paths of the data they refer to
+func sysrepoErrors(err error) []sr.ErrorInfo {
+	var e sr.Error
+	if errors.As(err, &e) {
+		return e.Errors
+	}
+	return nil
+}
+
+// errorMessage returns the messages of the errors sysrepo reported for
+// a failed edit, or the error itself if it reported none
+func errorMessage(err error) string {
+	var msgs []string
+	for _, info := range sysrepoErrors(err) {
+		msgs = append(msgs, info.Message)
+	}
+	if len(msgs) == 0 {
+		return err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// setItems sets leaves, relative to base, empty values delete them
+func setItems(sess *sr.Session, base string, leaves map[string]string) error {
+	for leaf, value := range leaves {
+		path := base + "/" + leaf
+
+		var err error
+		if value == "" {
+			err = sess.DeleteItem(path, sr.EditDefault)
+		} else {
+			err = sess.SetItem(path, &value, sr.EditDefault)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build ignore
+
+// Record the netlink dumps of netlink_test.go, as root, on a system set
+// up with:
+//
+//	ip link add br0 type bridge
+//	ip link add veth0 type veth peer name veth1
+//	ip link set veth0 master br0
+//	ip link set br0 up; ip link set veth0 up; ip link set veth1 up
+//	ip link add veth2 type veth peer name veth3
+//	ip link set veth2 up
+//	ip addr add 10.0.0.1/24 dev br0
+//	ip addr add 2001:db8::1/64 dev br0 nodad
+//	ip route add 198.51.100.0/24 via 192.0.2.1 table 100
+//	ip route add 192.0.2.128/25 dev veth2 table 100
+//	ip route add 203.0.113.0/24 nexthop via 192.0.2.1 weight 1 nexthop via 192.0.2.3 weight 2
+//	ip -6 route add 2001:db8:1::/48 nexthop via fd00::1 nexthop via fd00::3
+//	ip rule add pref 100 from 10.0.0.0/24 lookup 100
+//
+// where eth0 is 192.0.2.2/24 and fd00::2/64.  The kernel it was
+// recorded on has no 8021q, so the RTM_NEWLINK of VLAN eth0.10 is
+// built, the way the kernel sends it.  Run with:
+//
+//	go run testdata/record.go
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"os"
+	"syscall"
+)
+
+func main() {
+	dumps := []struct {
+		file    string
+		request int
+		family  int
+	}{
+		{"testdata/links.bin", syscall.RTM_GETLINK, syscall.AF_UNSPEC},
+		{"testdata/addrs.bin", syscall.RTM_GETADDR, syscall.AF_UNSPEC},
+		{"testdata/routes4.bin", syscall.RTM_GETROUTE, syscall.AF_INET},
+		{"testdata/routes6.bin", syscall.RTM_GETROUTE, syscall.AF_INET6},
+		{"testdata/rules4.bin", syscall.RTM_GETRULE, syscall.AF_INET},
+	}
+
+	for _, d := range dumps {
+		b, err := syscall.NetlinkRIB(d.request, d.family)
+		if err != nil {
+			log.Fatalf("%s: %v", d.file, err)
+		}
+		if err := os.WriteFile(d.file, b, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile("testdata/link-vlan.bin", vlanLink(12, "eth0.10", 4, 10), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// attr returns a padded netlink attribute
+func attr(typ uint16, value []byte) []byte {
+	b := binary.NativeEndian.AppendUint16(nil, uint16(syscall.SizeofRtAttr+len(value)))
+	b = binary.NativeEndian.AppendUint16(b, typ)
+	b = append(b, value...)
+	for len(b)%syscall.RTA_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// vlanLink returns the RTM_NEWLINK message of a VLAN on a lower link
+func vlanLink(index int, name string, lower int, vid uint16) []byte {
+	info := make([]byte, syscall.SizeofIfInfomsg)
+	binary.NativeEndian.PutUint16(info[2:4], syscall.ARPHRD_ETHER)
+	binary.NativeEndian.PutUint32(info[4:8], uint32(index))
+	binary.NativeEndian.PutUint32(info[8:12], syscall.IFF_UP|syscall.IFF_BROADCAST|syscall.IFF_MULTICAST|syscall.IFF_RUNNING)
+
+	body := info
+	body = append(body, attr(syscall.IFLA_IFNAME, append([]byte(name), 0))...)
+	body = append(body, attr(syscall.IFLA_OPERSTATE, []byte{6})...)
+	body = append(body, attr(syscall.IFLA_LINK, binary.NativeEndian.AppendUint32(nil, uint32(lower)))...)
+	body = append(body, attr(syscall.IFLA_ADDRESS, []byte{0x02, 0xfc, 0x00, 0x00, 0x00, 0x01})...)
+	data := attr(1, binary.NativeEndian.AppendUint16(nil, vid))                            // IFLA_VLAN_ID
+	linkinfo := append(attr(1, []byte("vlan\x00")), attr(2|syscall.NLA_F_NESTED, data)...) // IFLA_INFO_KIND, _DATA
+	body = append(body, attr(syscall.IFLA_LINKINFO|syscall.NLA_F_NESTED, linkinfo)...)
+
+	msg := binary.NativeEndian.AppendUint32(nil, uint32(syscall.NLMSG_HDRLEN+len(body)))
+	msg = binary.NativeEndian.AppendUint16(msg, syscall.RTM_NEWLINK)
+	msg = binary.NativeEndian.AppendUint16(msg, syscall.NLM_F_MULTI)
+	msg = append(msg, make([]byte, 8)...)
+	return append(msg, body...)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +21,10 @@ type UpgradeInfo struct {
 	FirmwareVersion string
 	BuildDate       string
 	ActiveBootslot  string
+	Compatible      string
+	LocalBundles    []LocalBundle
+	History         []UpgradeRecord
+	FirstBoot       *UpgradeRecord
 }
 
 // UpgradeStatus tracks the status of an upgrade
@@ -28,6 +34,7 @@ type UpgradeStatus struct {
 	Message    string  `json:"message"`
 	ShowReboot bool    `json:"show_reboot"`
 	Error      string  `json:"error,omitempty"`
+	Canceled   bool    `json:"canceled,omitempty"`
 }
 
 var (
@@ -52,61 +59,49 @@ func upgradeHandler(w http.ResponseWriter, r *http.Request) {
 
 // getUpgradeInfo retrieves the current firmware information
 func getUpgradeInfo() (*UpgradeInfo, error) {
-	// In a real system, this would come from actual system information
-	// For now, we'll use dummy data or try to get from RAUC
-
-	// Try to get information from RAUC
 	fwVersion := "Unknown"
 	buildDate := "Unknown"
 	activeSlot := "Unknown"
 
-	// Run rauc status to get current system info
-	cmd := exec.Command("rauc", "status")
-	output, err := cmd.CombinedOutput()
-	if err == nil {
-		// Parse RAUC output
-		outputStr := string(output)
-
-		// Extract firmware version
-		if versionLine := extractLine(outputStr, "version="); versionLine != "" {
-			fwVersion = strings.TrimPrefix(versionLine, "version=")
+	// The booted slot knows what bundle was installed to it, if it
+	// was installed by RAUC, otherwise fall back to os-release
+	status, err := getRaucStatus()
+	if err != nil {
+		log.Printf("Error getting RAUC status: %v", err)
+	} else if slot := status.BootedSlot(); slot != nil {
+		activeSlot = slot.Name
+		if slot.Bootname != "" {
+			activeSlot = fmt.Sprintf("%s (%s)", slot.Name, slot.Bootname)
 		}
-
-		// Extract build date
-		if dateLine := extractLine(outputStr, "build="); dateLine != "" {
-			buildDate = strings.TrimPrefix(dateLine, "build=")
+		if slot.SlotStatus.Bundle.Version != "" {
+			fwVersion = slot.SlotStatus.Bundle.Version
 		}
-
-		// Extract active bootslot
-		if bootLine := extractLine(outputStr, "booted from:"); bootLine != "" {
-			activeSlot = strings.TrimPrefix(bootLine, "booted from: ")
+		if slot.SlotStatus.Bundle.Build != "" {
+			buildDate = slot.SlotStatus.Bundle.Build
 		}
-	} else {
-		log.Printf("Error getting RAUC status: %v, using fallback values", err)
-		// Fallback values if RAUC is not available
-		fwVersion = "1.0.0"
-		buildDate = time.Now().Format("2006-01-02 15:04:05")
-		activeSlot = "slot A"
 	}
 
+	osRelease := getVersionInfo()
+	if version, ok := osRelease["VERSION"]; ok && fwVersion == "Unknown" {
+		fwVersion = version
+	}
+	if build, ok := osRelease["BUILD_ID"]; ok && buildDate == "Unknown" {
+		buildDate = build
+	}
+
+	history, firstBoot := getUpgradeHistory()
+
 	return &UpgradeInfo{
 		FirmwareVersion: fwVersion,
 		BuildDate:       buildDate,
 		ActiveBootslot:  activeSlot,
+		Compatible:      getSystemCompatible(),
+		LocalBundles:    scanFirmwareDirs(),
+		History:         history,
+		FirstBoot:       firstBoot,
 	}, nil
 }
 
-// extractLine extracts a line from multiline text that contains a specified prefix
-func extractLine(text, prefix string) string {
-	lines := strings.Split(text, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, prefix) {
-			return strings.TrimSpace(line)
-		}
-	}
-	return ""
-}
-
 // downloadConfigHandler handles downloading the current configuration
 func downloadConfigHandler(w http.ResponseWriter, r *http.Request) {
 	// In a real system, you would generate the actual config file
@@ -139,6 +134,20 @@ func downloadConfigHandler(w http.ResponseWriter, r *http.Request) {
 func uploadFirmwareHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Upload firmware request received")
 
+	// Only one upgrade at a time, and no reboot or reset mid-install
+	op, err := beginOperation("Firmware upgrade", getUsername(r))
+	if err != nil {
+		log.Printf("Refusing firmware upgrade: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	started := false
+	defer func() {
+		if !started {
+			op.End()
+		}
+	}()
+
 	// Create upload directory if it doesn't exist
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		log.Printf("Error creating upload directory: %v", err)
@@ -153,90 +162,157 @@ func uploadFirmwareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get firmware file
-	firmwareFile, firmwareHeader, err := r.FormFile("firmware")
-	if err != nil {
-		log.Printf("Error getting firmware file: %v", err)
-		http.Error(w, "No firmware file provided", http.StatusBadRequest)
+	src, ok := receiveFirmware(w, r, filepath.Join(uploadDir, "firmware.pkg"))
+	if !ok {
 		return
 	}
-	defer firmwareFile.Close()
-
-	log.Printf("Firmware file received: %s", firmwareHeader.Filename)
 
-	// Validate firmware file
-	if !strings.HasSuffix(strings.ToLower(firmwareHeader.Filename), ".pkg") {
-		log.Printf("Invalid firmware file extension: %s", firmwareHeader.Filename)
-		http.Error(w, "Invalid firmware file. File must have .pkg extension", http.StatusBadRequest)
+	configPath, ok := receiveConfig(w, r, filepath.Join(uploadDir, "config.cfg"))
+	if !ok {
 		return
 	}
 
-	// Save firmware file
-	firmwarePath := filepath.Join(uploadDir, "firmware.pkg")
-	firmwareOut, err := os.Create(firmwarePath)
-	if err != nil {
-		log.Printf("Error creating firmware file: %v", err)
-		http.Error(w, "Failed to save firmware file", http.StatusInternalServerError)
-		return
+	// Reset upgrade status
+	currentUpgradeMutex.Lock()
+	currentUpgrade = UpgradeStatus{
+		Status:   "uploading",
+		Progress: 0,
+		Message:  "Firmware received successfully, starting installation...",
 	}
-	defer firmwareOut.Close()
+	currentUpgradeMutex.Unlock()
 
-	if _, err := io.Copy(firmwareOut, firmwareFile); err != nil {
-		log.Printf("Error saving firmware file: %v", err)
-		http.Error(w, "Failed to save firmware file", http.StatusInternalServerError)
-		return
-	}
+	// Start the upgrade process in a goroutine
+	rec := newUpgradeRecord(getUsername(r), remoteAddr(r), src)
+	started = true
+	go func() {
+		defer op.End()
+		startUpgradeProcess(op, src, configPath, rec)
+	}()
 
-	log.Printf("Firmware file saved to %s", firmwarePath)
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentUpgrade)
+	log.Printf("Upload firmware response sent, starting upgrade process")
+}
 
-	// Check if config file was also uploaded
-	configPath := ""
-	configFile, configHeader, err := r.FormFile("config")
-	if err == nil {
-		defer configFile.Close()
+// receiveFirmware gets the firmware from a parsed upgrade form, saving
+// uploaded bundles to firmwarePath.  On error a response has been sent.
+func receiveFirmware(w http.ResponseWriter, r *http.Request, firmwarePath string) (FirmwareSource, bool) {
+	// Firmware either comes from the browser, as a completed chunked
+	// upload or as a file in the form itself, from a URL, or from
+	// local storage
+	var src FirmwareSource
+	switch source := r.FormValue("source"); source {
+	case "", sourceUpload:
+		if id := r.FormValue("upload"); id != "" {
+			up, err := claimUpload(id, firmwarePath)
+			if err != nil {
+				log.Printf("Error claiming upload %s: %v", id, err)
+				http.Error(w, fmt.Sprintf("Invalid firmware upload: %v", err), http.StatusBadRequest)
+				return src, false
+			}
 
-		// Validate config file
-		if !strings.HasSuffix(strings.ToLower(configHeader.Filename), ".cfg") {
-			log.Printf("Invalid config file extension: %s", configHeader.Filename)
-			http.Error(w, "Invalid configuration file. File must have .cfg extension", http.StatusBadRequest)
-			return
+			src = FirmwareSource{Type: sourceUpload, Path: firmwarePath, Name: up.Name, SHA256: up.SHA256}
+			log.Printf("Firmware file %s (sha256 %s) saved to %s", up.Name, up.SHA256, firmwarePath)
+			break
 		}
 
-		// Save config file
-		configPath = filepath.Join(uploadDir, "config.cfg")
-		configOut, err := os.Create(configPath)
+		// Get firmware file
+		firmwareFile, firmwareHeader, err := r.FormFile("firmware")
 		if err != nil {
-			log.Printf("Error creating config file: %v", err)
-			http.Error(w, "Failed to save configuration file", http.StatusInternalServerError)
-			return
+			log.Printf("Error getting firmware file: %v", err)
+			http.Error(w, "No firmware file provided", http.StatusBadRequest)
+			return src, false
 		}
-		defer configOut.Close()
+		defer firmwareFile.Close()
 
-		if _, err := io.Copy(configOut, configFile); err != nil {
-			log.Printf("Error saving config file: %v", err)
-			http.Error(w, "Failed to save configuration file", http.StatusInternalServerError)
-			return
+		log.Printf("Firmware file received: %s", firmwareHeader.Filename)
+
+		// Validate firmware file
+		if !strings.HasSuffix(strings.ToLower(firmwareHeader.Filename), ".pkg") {
+			log.Printf("Invalid firmware file extension: %s", firmwareHeader.Filename)
+			http.Error(w, "Invalid firmware file. File must have .pkg extension", http.StatusBadRequest)
+			return src, false
+		}
+
+		// Save firmware file
+		firmwareOut, err := os.Create(firmwarePath)
+		if err != nil {
+			log.Printf("Error creating firmware file: %v", err)
+			http.Error(w, "Failed to save firmware file", http.StatusInternalServerError)
+			return src, false
+		}
+		defer firmwareOut.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(firmwareOut, hash), firmwareFile); err != nil {
+			log.Printf("Error saving firmware file: %v", err)
+			http.Error(w, "Failed to save firmware file", http.StatusInternalServerError)
+			return src, false
 		}
 
-		log.Printf("Config file saved to %s", configPath)
+		src = FirmwareSource{
+			Type:   sourceUpload,
+			Path:   firmwarePath,
+			Name:   filepath.Base(firmwareHeader.Filename),
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		}
+		log.Printf("Firmware file saved to %s", firmwarePath)
+
+	case sourceURL:
+		src = newFirmwareSource(sourceURL, r.FormValue("url"))
+
+	case sourceLocal:
+		src = newFirmwareSource(sourceLocal, r.FormValue("path"))
+
+	default:
+		http.Error(w, "Unknown firmware source", http.StatusBadRequest)
+		return src, false
 	}
 
-	// Reset upgrade status
-	currentUpgradeMutex.Lock()
-	currentUpgrade = UpgradeStatus{
-		Status:   "uploading",
-		Progress: 0,
-		Message:  "Files uploaded successfully, starting installation...",
+	if err := validateFirmwareSource(src); err != nil {
+		log.Printf("Invalid firmware %s: %v", src, err)
+		http.Error(w, fmt.Sprintf("Invalid firmware: %v", err), http.StatusBadRequest)
+		return src, false
 	}
-	currentUpgradeMutex.Unlock()
 
-	// Start the upgrade process in a goroutine
-	go startUpgradeProcess(firmwarePath, configPath)
+	return src, true
+}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(currentUpgrade)
-	log.Printf("Upload firmware response sent, starting upgrade process")
+// receiveConfig saves the optional configuration file from a parsed
+// upgrade form to configPath.  Returns the empty string if there is no
+// configuration file.  On error a response has been sent.
+func receiveConfig(w http.ResponseWriter, r *http.Request, configPath string) (string, bool) {
+	configFile, configHeader, err := r.FormFile("config")
+	if err != nil {
+		return "", true
+	}
+	defer configFile.Close()
+
+	// Validate config file
+	if !strings.HasSuffix(strings.ToLower(configHeader.Filename), ".cfg") {
+		log.Printf("Invalid config file extension: %s", configHeader.Filename)
+		http.Error(w, "Invalid configuration file. File must have .cfg extension", http.StatusBadRequest)
+		return "", false
+	}
+
+	// Save config file
+	configOut, err := os.Create(configPath)
+	if err != nil {
+		log.Printf("Error creating config file: %v", err)
+		http.Error(w, "Failed to save configuration file", http.StatusInternalServerError)
+		return "", false
+	}
+	defer configOut.Close()
+
+	if _, err := io.Copy(configOut, configFile); err != nil {
+		log.Printf("Error saving config file: %v", err)
+		http.Error(w, "Failed to save configuration file", http.StatusInternalServerError)
+		return "", false
+	}
+
+	log.Printf("Config file saved to %s", configPath)
+	return configPath, true
 }
 
 // upgradeStatusHandler returns the current upgrade status
@@ -249,32 +325,63 @@ func upgradeStatusHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(status)
 }
 
-// startUpgradeProcess simulates the RAUC upgrade process
-func startUpgradeProcess(firmwarePath, configPath string) {
-	log.Printf("Starting upgrade process for %s", firmwarePath)
+// startUpgradeProcess simulates the RAUC upgrade process, the outcome
+// is recorded in the upgrade history.  The upgrade can be canceled
+// until RAUC starts writing to the slot.
+func startUpgradeProcess(op *Operation, src FirmwareSource, configPath string, rec *UpgradeRecord) {
+	log.Printf("Starting upgrade process for %s", src)
+
+	defer func() {
+		currentUpgradeMutex.Lock()
+		status := currentUpgrade
+		currentUpgradeMutex.Unlock()
+
+		finishUpgradeRecord(rec, status)
+	}()
 
 	// Update status to installing
 	updateUpgradeStatus("installing", 5, "Starting installation process...")
 
-	// Validate firmware package (in a real system, this would check signatures, etc.)
-	log.Printf("Validating firmware package: %s", firmwarePath)
-	time.Sleep(2 * time.Second)
-	updateUpgradeStatus("installing", 10, "Validating firmware package...")
-
 	// Check if RAUC is available
 	_, err := exec.LookPath("rauc")
 	usingRauc := err == nil
 
 	if usingRauc {
+		// Validate firmware package, signature and compatible
+		log.Printf("Validating firmware package: %s", src)
+		updateUpgradeStatus("installing", 10, "Validating firmware package...")
+
+		info, err := inspectBundle(src.Path)
+		if err == nil {
+			err = checkBundle(info)
+		}
+		if err != nil {
+			log.Printf("Refusing to install %s: %v", src, err)
+			updateUpgradeStatus("error", 10, fmt.Sprintf("Invalid firmware package: %v", err))
+			return
+		}
+
+		rec.Version = info.Version
+		saveUpgradeRecord(rec)
+
+		// Killing the rauc client does not stop the installation in
+		// the RAUC service, so this is the last chance to cancel
+		op.SetCancelable(false)
+		if op.Context().Err() != nil {
+			upgradeCanceled()
+			return
+		}
+
 		// Using actual RAUC command
-		log.Printf("RAUC available, performing actual installation")
+		log.Printf("RAUC available, performing actual installation of version %s", info.Version)
 		updateUpgradeStatus("installing", 15, "Starting RAUC installation...")
 
 		// Set up a command to run RAUC
-		cmd := exec.Command("rauc", "install", firmwarePath)
+		// RAUC streams bundles from http(s) URLs natively
+		cmd := exec.Command("rauc", "install", src.Path)
 
 		// Start the command
-		err := cmd.Start()
+		err = cmd.Start()
 		if err != nil {
 			log.Printf("Error starting RAUC: %v", err)
 			updateUpgradeStatus("error", 15, "Failed to start installation")
@@ -302,17 +409,31 @@ func startUpgradeProcess(firmwarePath, configPath string) {
 			return
 		}
 
+		// RAUC makes the slot it just wrote the primary boot slot
+		if status, err := getRaucStatus(); err == nil {
+			rec.Slot = status.BootPrimary
+		}
+
 	} else {
 		// Simulate RAUC installation process
 		log.Printf("RAUC not available, simulating installation process")
+		time.Sleep(2 * time.Second)
+		updateUpgradeStatus("installing", 10, "Validating firmware package...")
 		updateUpgradeStatus("installing", 15, "Preparing installation environment...")
 
 		// Simulate installation progress
 		for i := 20; i <= 90; i += 5 {
 			updateUpgradeStatus("installing", float64(i), fmt.Sprintf("Installing firmware (%d%%)", i))
 			log.Printf("Installation progress: %d%%", i)
-			time.Sleep(1 * time.Second)
+
+			select {
+			case <-op.Context().Done():
+				upgradeCanceled()
+				return
+			case <-time.After(1 * time.Second):
+			}
 		}
+		op.SetCancelable(false)
 	}
 
 	// If config file was provided, apply it
@@ -327,6 +448,17 @@ func startUpgradeProcess(firmwarePath, configPath string) {
 	log.Printf("Upgrade process completed")
 }
 
+// upgradeCanceled updates the upgrade status after a cancel request
+func upgradeCanceled() {
+	log.Printf("Upgrade process canceled")
+
+	currentUpgradeMutex.Lock()
+	currentUpgrade.Canceled = true
+	currentUpgradeMutex.Unlock()
+
+	updateUpgradeStatus("error", 0, "Installation canceled")
+}
+
 // updateUpgradeStatus updates the current upgrade status
 func updateUpgradeStatus(status string, progress float64, message string) {
 	currentUpgradeMutex.Lock()
@@ -354,6 +486,14 @@ func rebootHandler(w http.ResponseWriter, r *http.Request) {
 	// Log the reboot request
 	log.Println("Reboot requested by user:", getUsername(r))
 
+	op, err := beginOperation("Reboot", getUsername(r))
+	if err != nil {
+		log.Printf("Refusing reboot: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	op.SetCancelable(false)
+
 	// Set appropriate response headers
 	w.Header().Set("Content-Type", "application/json")
 
@@ -363,11 +503,16 @@ func rebootHandler(w http.ResponseWriter, r *http.Request) {
 		"message": "System is rebooting...",
 	})
 
+	go rebootSystem(op)
+}
+
+// rebootSystem reboots the system, the operation never ends unless
+// the reboot fails, or is simulated
+func rebootSystem(op *Operation) {
 	// In a real system, you would trigger the reboot here
-	go func() {
-		log.Println("Simulating system reboot...")
-		// In a real environment, you would execute a reboot command here
-		time.Sleep(2 * time.Second)
-		log.Println("Reboot simulation complete")
-	}()
+	log.Println("Simulating system reboot...")
+	// In a real environment, you would execute a reboot command here
+	time.Sleep(2 * time.Second)
+	log.Println("Reboot simulation complete")
+	op.End()
 }
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Upload tracks a resumable, chunked firmware upload.  The partial
+// bundle is kept in uploadDir next to a JSON sidecar, with the last
+// acknowledged offset, so an upload can be resumed from it even if the
+// browser, the link, or the webui itself goes away in between.
+type Upload struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Length   int64     `json:"length"`
+	Offset   int64     `json:"offset"`
+	Checksum string    `json:"checksum,omitempty"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Complete bool      `json:"complete"`
+	Created  time.Time `json:"created"`
+}
+
+const (
+	// Largest accepted bundle, same limit as the multipart form
+	maxUploadLength = 500 << 20
+	// Largest accepted chunk in a single PATCH request
+	maxChunkLength = 16 << 20
+	// Unfinished uploads older than this are removed
+	uploadExpiry = 24 * time.Hour
+	// Time allowed to receive a single chunk
+	chunkTimeout = 2 * time.Minute
+)
+
+var (
+	uploadsMutex sync.Mutex
+	// Uploads with a chunk being received
+	activeUploads = make(map[string]bool)
+)
+
+// uploadPath returns the path to the partial bundle of an upload
+func uploadPath(id string) string {
+	return filepath.Join(uploadDir, id+".part")
+}
+
+// uploadMetaPath returns the path to the JSON sidecar of an upload
+func uploadMetaPath(id string) string {
+	return filepath.Join(uploadDir, id+".json")
+}
+
+// validUploadID checks that id is one of ours, i.e., safe to use in a path
+func validUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// loadUpload reads the sidecar of an upload.  The partial bundle may
+// have more data than acknowledged, of a chunk not fully received, but
+// never less.
+func loadUpload(id string) (*Upload, error) {
+	if !validUploadID(id) {
+		return nil, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(uploadMetaPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var up Upload
+	if err := json.Unmarshal(data, &up); err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(uploadPath(id))
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < up.Offset {
+		return nil, fmt.Errorf("upload %s: %d bytes acknowledged, %d saved", id, up.Offset, fi.Size())
+	}
+
+	return &up, nil
+}
+
+// saveUpload writes the sidecar of an upload, atomically since it may
+// be read while a chunk is being received, and synced, since a chunk
+// is acknowledged when its offset is saved
+func saveUpload(up *Upload) error {
+	data, err := json.Marshal(up)
+	if err != nil {
+		return err
+	}
+
+	tmp := uploadMetaPath(up.ID) + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, uploadMetaPath(up.ID))
+}
+
+// removeUpload deletes an upload and its partial bundle
+func removeUpload(id string) {
+	os.Remove(uploadPath(id))
+	os.Remove(uploadMetaPath(id))
+}
+
+// expireUploads removes abandoned uploads
+func expireUploads() {
+	files, err := filepath.Glob(filepath.Join(uploadDir, "*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".json")
+		up, err := loadUpload(id)
+		if err != nil || time.Since(up.Created) > uploadExpiry {
+			log.Printf("Removing stale upload %s", id)
+			removeUpload(id)
+		}
+	}
+}
+
+// setUploadHeaders sets the protocol headers describing an upload
+func setUploadHeaders(w http.ResponseWriter, up *Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(up.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// parseChecksum parses an "Upload-Checksum: sha256 <base64>" header
+func parseChecksum(header string) ([]byte, error) {
+	algo, value, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || strings.ToLower(algo) != "sha256" {
+		return nil, errors.New("only sha256 checksums are supported")
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errors.New("malformed sha256 checksum")
+	}
+
+	return sum, nil
+}
+
+// createUploadHandler starts a new chunked upload.  The client sends
+// the total length of the bundle in Upload-Length, the file name in
+// Upload-Name and the SHA-256 of the whole bundle in Upload-Checksum,
+// to verify the bundle with when it has been received.
+func createUploadHandler(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Missing or invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > maxUploadLength {
+		http.Error(w, "Firmware file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	name := filepath.Base(r.Header.Get("Upload-Name"))
+	if !strings.HasSuffix(strings.ToLower(name), ".pkg") {
+		http.Error(w, "Invalid firmware file. File must have .pkg extension", http.StatusBadRequest)
+		return
+	}
+
+	header := r.Header.Get("Upload-Checksum")
+	if header == "" {
+		http.Error(w, "Missing Upload-Checksum, the SHA-256 of the firmware file", http.StatusBadRequest)
+		return
+	}
+	sum, err := parseChecksum(header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checksum := hex.EncodeToString(sum)
+
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		log.Printf("Error creating upload directory: %v", err)
+		http.Error(w, "Failed to prepare for upload", http.StatusInternalServerError)
+		return
+	}
+
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+
+	expireUploads()
+
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	up := &Upload{
+		ID:       hex.EncodeToString(id),
+		Name:     name,
+		Length:   length,
+		Checksum: checksum,
+		Created:  time.Now(),
+	}
+
+	if err := os.WriteFile(uploadPath(up.ID), nil, 0600); err != nil {
+		log.Printf("Error creating upload file: %v", err)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	if err := saveUpload(up); err != nil {
+		log.Printf("Error saving upload %s: %v", up.ID, err)
+		removeUpload(up.ID)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Upload %s of %s (%d bytes) created by user: %s", up.ID, name, length, getUsername(r))
+
+	setUploadHeaders(w, up)
+	w.Header().Set("Location", "/uploads/"+up.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(up)
+}
+
+// uploadOffsetHandler reports how much of an upload has been received
+func uploadOffsetHandler(w http.ResponseWriter, r *http.Request) {
+	uploadsMutex.Lock()
+	up, err := loadUpload(chi.URLParam(r, "id"))
+	uploadsMutex.Unlock()
+	if err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	setUploadHeaders(w, up)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(up)
+}
+
+// uploadChunkHandler appends a chunk to an upload.  The chunk must
+// start at the current offset, given in Upload-Offset, and may carry
+// an Upload-Checksum of its own.  A chunk is only acknowledged, its
+// offset saved in the sidecar, when it has been fully received,
+// verified and synced, otherwise the partial bundle is truncated back
+// to the previous offset.  When the final chunk arrives the whole
+// bundle is hashed and verified against the checksum of the upload.
+func uploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	uploadsMutex.Lock()
+	up, err := beginChunk(w, r, id)
+	uploadsMutex.Unlock()
+	if err != nil {
+		return
+	}
+
+	status, err := receiveChunk(w, r, up)
+
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+	delete(activeUploads, id)
+
+	if err != nil {
+		setUploadHeaders(w, up)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	setUploadHeaders(w, up)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// beginChunk checks that a chunk can be received for an upload and
+// marks the upload as busy, on error the response has been sent
+func beginChunk(w http.ResponseWriter, r *http.Request, id string) (*Upload, error) {
+	up, err := loadUpload(id)
+	if err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return nil, err
+	}
+
+	if activeUploads[id] {
+		setUploadHeaders(w, up)
+		http.Error(w, "Another chunk is being received", http.StatusConflict)
+		return nil, errors.New("busy")
+	}
+
+	if up.Complete {
+		setUploadHeaders(w, up)
+		http.Error(w, "Upload already complete", http.StatusConflict)
+		return nil, errors.New("complete")
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Missing or invalid Upload-Offset", http.StatusBadRequest)
+		return nil, err
+	}
+	if offset != up.Offset {
+		setUploadHeaders(w, up)
+		http.Error(w, "Upload-Offset does not match received data", http.StatusConflict)
+		return nil, errors.New("offset mismatch")
+	}
+
+	activeUploads[id] = true
+	return up, nil
+}
+
+// receiveChunk writes a chunk to the partial bundle, without holding
+// uploadsMutex, and advances the offset of the upload when the chunk
+// has been verified.  Returns the HTTP status to reply with on error.
+func receiveChunk(w http.ResponseWriter, r *http.Request, up *Upload) (int, error) {
+	var want []byte
+	var err error
+
+	offset := up.Offset
+	if header := r.Header.Get("Upload-Checksum"); header != "" {
+		if want, err = parseChecksum(header); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	// Do not let a stalled connection keep the upload busy forever
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(chunkTimeout))
+
+	file, err := os.OpenFile(uploadPath(up.ID), os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Error opening upload %s: %v", up.ID, err)
+		return http.StatusInternalServerError, errors.New("Failed to save firmware file")
+	}
+	defer file.Close()
+
+	// Drop data of an earlier chunk never acknowledged, e.g., cut off
+	// by a restart of the webui
+	if err := file.Truncate(offset); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to save firmware file")
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to save firmware file")
+	}
+
+	limit := min(up.Length-offset, maxChunkLength)
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(r.Body, limit+1))
+	if err == nil && n > limit {
+		err = errors.New("chunk exceeds upload length")
+	}
+	if err == nil && want != nil && string(hash.Sum(nil)) != string(want) {
+		err = errors.New("chunk checksum mismatch")
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil && offset+n < up.Length {
+		up.Offset = offset + n
+		if err = saveUpload(up); err != nil {
+			up.Offset = offset
+		}
+	}
+	if err != nil {
+		log.Printf("Discarding chunk at offset %d of upload %s: %v", offset, up.ID, err)
+		file.Truncate(offset)
+		return http.StatusUnprocessableEntity, fmt.Errorf("Chunk rejected: %v", err)
+	}
+
+	if offset+n == up.Length {
+		up.Offset = up.Length
+		if err := finishUpload(up); err != nil {
+			log.Printf("Upload %s failed verification: %v", up.ID, err)
+			removeUpload(up.ID)
+			return http.StatusUnprocessableEntity, fmt.Errorf("Upload failed verification: %v", err)
+		}
+		log.Printf("Upload %s complete, sha256 %s", up.ID, up.SHA256)
+	}
+
+	return 0, nil
+}
+
+// finishUpload hashes a fully received bundle and verifies it against
+// the checksum the client sent when the upload was created
+func finishUpload(up *Upload) error {
+	file, err := os.Open(uploadPath(up.ID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	up.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if up.Checksum == "" {
+		return errors.New("no checksum to verify against")
+	}
+	if up.Checksum != up.SHA256 {
+		return fmt.Errorf("sha256 %s, expected %s", up.SHA256, up.Checksum)
+	}
+
+	up.Complete = true
+	return saveUpload(up)
+}
+
+// deleteUploadHandler aborts an upload
+func deleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+
+	if _, err := loadUpload(id); err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	removeUpload(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkUpload returns an upload if it has been completely received
+func checkUpload(id string) (*Upload, error) {
+	up, err := loadUpload(id)
+	if err != nil {
+		return nil, fmt.Errorf("upload not found")
+	}
+	if !up.Complete {
+		return nil, fmt.Errorf("upload incomplete, %d of %d bytes received", up.Offset, up.Length)
+	}
+
+	return up, nil
+}
+
+// completedUpload looks up an upload that has been completely received
+func completedUpload(id string) (*Upload, error) {
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+
+	return checkUpload(id)
+}
+
+// claimUpload moves a completed upload to dst, the upload itself is
+// consumed in the process
+func claimUpload(id, dst string) (*Upload, error) {
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+
+	up, err := checkUpload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := moveFile(uploadPath(id), dst); err != nil {
+		return nil, err
+	}
+	os.Remove(uploadMetaPath(id))
+
+	return up, nil
+}
+
+// moveFile renames a file, or copies it when dst is on another file
+// system, e.g., from a tmpfs to persistent storage
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// uploadRouter returns the upload routes, saving to a new uploadDir
+func uploadRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	saved := uploadDir
+	uploadDir = t.TempDir()
+	t.Cleanup(func() { uploadDir = saved })
+
+	r := chi.NewRouter()
+	r.Post("/uploads", createUploadHandler)
+	r.Head("/uploads/{id}", uploadOffsetHandler)
+	r.Patch("/uploads/{id}", uploadChunkHandler)
+	return r
+}
+
+// checksum returns the Upload-Checksum header of data
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// uploadRequest sends a request to the upload routes
+func uploadRequest(h http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// createUpload starts an upload of bundle, returns its location
+func createUpload(t *testing.T, h http.Handler, bundle, sum string) string {
+	t.Helper()
+
+	w := uploadRequest(h, http.MethodPost, "/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(len(bundle)),
+		"Upload-Name":     "infix.pkg",
+		"Upload-Checksum": sum,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", w.Code, w.Body)
+	}
+	return w.Header().Get("Location")
+}
+
+// sendChunk sends a chunk at offset, with its checksum if sum is set
+func sendChunk(h http.Handler, location string, offset int, chunk, sum string) *httptest.ResponseRecorder {
+	headers := map[string]string{"Upload-Offset": strconv.Itoa(offset)}
+	if sum != "" {
+		headers["Upload-Checksum"] = sum
+	}
+	return uploadRequest(h, http.MethodPatch, location, chunk, headers)
+}
+
+// uploadOffset returns the acknowledged offset of an upload
+func uploadOffset(t *testing.T, h http.Handler, location string) string {
+	t.Helper()
+
+	w := uploadRequest(h, http.MethodHead, location, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("HEAD %s = %d", location, w.Code)
+	}
+	return w.Header().Get("Upload-Offset")
+}
+
+func TestCreateUploadChecksum(t *testing.T) {
+	h := uploadRouter(t)
+
+	for _, sum := range []string{"", "md5 1B2M2Y8AsgTpgAmY7PhCfg==", "sha256 Zm9v"} {
+		w := uploadRequest(h, http.MethodPost, "/uploads", "", map[string]string{
+			"Upload-Length":   "1024",
+			"Upload-Name":     "infix.pkg",
+			"Upload-Checksum": sum,
+		})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("create with checksum %q = %d, want %d", sum, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUploadChunks(t *testing.T) {
+	h := uploadRouter(t)
+	bundle := strings.Repeat("0123456789abcdef", 64)
+	location := createUpload(t, h, bundle, checksum(bundle))
+
+	w := sendChunk(h, location, 0, bundle[:400], checksum(bundle[:400]))
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "400" {
+		t.Fatalf("first chunk = %d, offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	// Resent, or sent from the wrong offset
+	for _, offset := range []int{0, 500} {
+		w = sendChunk(h, location, offset, bundle[offset:offset+100], "")
+		if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "400" {
+			t.Errorf("chunk at %d = %d, offset %s, want %d, offset 400",
+				offset, w.Code, w.Header().Get("Upload-Offset"), http.StatusConflict)
+		}
+	}
+
+	// Corrupted on the way
+	w = sendChunk(h, location, 400, strings.ToUpper(bundle[400:800]), checksum(bundle[400:800]))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("corrupt chunk = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if got := uploadOffset(t, h, location); got != "400" {
+		t.Errorf("offset after corrupt chunk = %s, want 400", got)
+	}
+
+	w = sendChunk(h, location, 400, bundle[400:], checksum(bundle[400:]))
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != strconv.Itoa(len(bundle)) {
+		t.Fatalf("last chunk = %d %s, offset %s", w.Code, w.Body, w.Header().Get("Upload-Offset"))
+	}
+
+	up, err := completedUpload(strings.TrimPrefix(location, "/uploads/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(uploadPath(up.ID))
+	if string(data) != bundle {
+		t.Errorf("bundle = %d bytes, want %d", len(data), len(bundle))
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	h := uploadRouter(t)
+	bundle := strings.Repeat("0123456789abcdef", 64)
+	location := createUpload(t, h, bundle, checksum(bundle))
+	id := strings.TrimPrefix(location, "/uploads/")
+
+	if w := sendChunk(h, location, 0, bundle[:300], ""); w.Code != http.StatusNoContent {
+		t.Fatalf("first chunk = %d %s", w.Code, w.Body)
+	}
+
+	// The webui restarted while receiving the next chunk, part of it
+	// is on disk but was never acknowledged
+	file, err := os.OpenFile(uploadPath(id), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("garbage from a chunk cut off")
+	file.Close()
+
+	if got := uploadOffset(t, h, location); got != "300" {
+		t.Fatalf("offset after restart = %s, want 300", got)
+	}
+
+	if w := sendChunk(h, location, 300, bundle[300:], ""); w.Code != http.StatusNoContent {
+		t.Fatalf("resumed chunk = %d %s", w.Code, w.Body)
+	}
+	if _, err := completedUpload(id); err != nil {
+		t.Errorf("resumed upload not complete: %v", err)
+	}
+}
+
+func TestUploadVerified(t *testing.T) {
+	h := uploadRouter(t)
+	bundle := strings.Repeat("0123456789abcdef", 64)
+	location := createUpload(t, h, bundle, checksum("another bundle"))
+	id := strings.TrimPrefix(location, "/uploads/")
+
+	w := sendChunk(h, location, 0, bundle, "")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("mismatching bundle = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if _, err := os.Stat(uploadPath(id)); !os.IsNotExist(err) {
+		t.Errorf("mismatching bundle kept: %v", err)
+	}
+	if w := uploadRequest(h, http.MethodHead, location, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD mismatching upload = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/mattiaswal/go-sysrepo
+
+go 1.22.2
@@ -0,0 +1,17 @@
+package sysrepo
+
+import "regexp"
+
+// pathRe matches the path sysrepo adds to the messages of libyang
+// errors, `(path "...")`, and libyang itself, `(Data location "...").`
+var pathRe = regexp.MustCompile(`\s*\((?:path|Data location|Schema location) "([^"]+)"\)\.?$`)
+
+// splitPath returns an error message without the path it ends with,
+// and the path, which is empty if the message has none
+func splitPath(msg string) (string, string) {
+	m := pathRe.FindStringSubmatchIndex(msg)
+	if m == nil {
+		return msg, ""
+	}
+	return msg[:m[0]], msg[m[2]:m[3]]
+}
@@ -0,0 +1,37 @@
+package sysrepo
+
+import "testing"
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		msg, want, path string
+	}{
+		{
+			`Value "70000" does not satisfy the range constraint. (path "/ietf-interfaces:interfaces/interface[name='e1']/ietf-ip:ipv4/mtu")`,
+			`Value "70000" does not satisfy the range constraint.`,
+			`/ietf-interfaces:interfaces/interface[name='e1']/ietf-ip:ipv4/mtu`,
+		},
+		{
+			`Duplicate instance of "address". (Data location "/ietf-interfaces:interfaces/interface[name='e1']/ietf-ip:ipv4/address[ip='10.0.0.1']").`,
+			`Duplicate instance of "address".`,
+			`/ietf-interfaces:interfaces/interface[name='e1']/ietf-ip:ipv4/address[ip='10.0.0.1']`,
+		},
+		{
+			`Invalid identityref "foo" value - unable to map prefix to YANG schema. (Schema location "/ietf-interfaces:interfaces/interface/type")`,
+			`Invalid identityref "foo" value - unable to map prefix to YANG schema.`,
+			`/ietf-interfaces:interfaces/interface/type`,
+		},
+		{
+			`Operation failed`,
+			`Operation failed`,
+			``,
+		},
+	}
+
+	for _, tt := range tests {
+		msg, path := splitPath(tt.msg)
+		if msg != tt.want || path != tt.path {
+			t.Errorf("splitPath(%q) = %q, %q, want %q, %q", tt.msg, msg, path, tt.want, tt.path)
+		}
+	}
+}